@@ -1,3 +1,7 @@
 module github.com/cc-jj/pulse
 
 go 1.24.3
+
+require gopkg.in/yaml.v3 v3.0.1
+
+require github.com/BurntSushi/toml v1.6.0
@@ -1,15 +1,42 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/scanner"
+	"go/token"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
@@ -17,79 +44,524 @@ import (
 const (
 	Version           = "0.0.3"
 	DefaultConfigPath = "pulse.json"
+
+	// Number of output lines kept for crash reports
+	crashReportLines = 50
+
+	// Records the state of the migration files that were last migrated
+	migrationLockFile = ".pulse.migration.lock"
+
+	// Minimum time between two govulncheck runs
+	vulnCheckInterval = time.Minute
+
+	// Name of the file injected into the main package by profile_memory
+	pprofFile = "pulse_pprof.go"
+
+	// Caches go list output for go_list_cache
+	goListCacheFile = ".pulse.golist.cache"
 )
 
 type Config struct {
-	MainFile      string   `json:"main_file"`
-	BinaryName    string   `json:"binary_name"`
-	WatchDir      string   `json:"watch_dir"`
-	WatchExts     []string `json:"watch_exts"`
-	WatchInterval string   `json:"watch_interval"`
-	MaxWatchers   int      `json:"max_watchers"`
+	MainFile                       string            `json:"main_file"`
+	BinaryName                     string            `json:"binary_name"`
+	WatchDir                       string            `json:"watch_dir"`
+	WatchDirs                      []string          `json:"watch_dirs"`
+	WatchExts                      []string          `json:"watch_exts"`
+	WatchInterval                  string            `json:"watch_interval"`
+	MaxWatchers                    int               `json:"max_watchers"`
+	WatchGlobDirs                  []string          `json:"watch_glob_dirs"`
+	QuietPeriodMs                  int               `json:"quiet_period_ms"`
+	WatchMode                      string            `json:"watch_mode"`
+	TestParallel                   int               `json:"test_parallel"`
+	ShowFormatIssues               bool              `json:"format_on_build_failure"`
+	EnvFile                        string            `json:"env_file"`
+	Env                            map[string]string `json:"env"`
+	WatchEnvFile                   bool              `json:"watch_env_file"`
+	WatchConfig                    bool              `json:"watch_config"`
+	TestCount                      int               `json:"test_count"`
+	TagFile                        string            `json:"tag_file"`
+	WatchGoEmbed                   bool              `json:"watch_go_embed"`
+	CrashReport                    bool              `json:"process_crash_report"`
+	ArtifactDir                    string            `json:"artifact_dir"`
+	OnCrash                        string            `json:"on_crash"`
+	OnFileChange                   string            `json:"on_file_change"`
+	RunAsModule                    bool              `json:"run_as_module"`
+	UseGoRun                       bool              `json:"use_go_run"`
+	MainPackage                    string            `json:"main_package"`
+	Args                           []string          `json:"args"`
+	IntervalUnit                   string            `json:"watch_interval_unit"`
+	IntervalValue                  int               `json:"watch_interval_value"`
+	SkipBuildIfOnlyCommentsChanged bool              `json:"skip_build_if_only_comments_changed"`
+	WatchChangeThreshold           int               `json:"watch_change_threshold"`
+	InjectBuildTime                bool              `json:"inject_build_time"`
+	WatchExternalCommand           string            `json:"watch_external_command"`
+	WatchGitStash                  bool              `json:"watch_git_stash"`
+	WatchOnly                      bool              `json:"watch_only"`
+	RunCommand                     []string          `json:"run_command"`
+	OnReady                        string            `json:"on_ready"`
+	TestShort                      bool              `json:"test_short"`
+	WatchOnlyChangedPackages       bool              `json:"watch_only_changed_packages"`
+	RestartOnSuccessOnly           bool              `json:"restart_on_success_only"`
+	WatchErrorBackoff              bool              `json:"watch_error_backoff"`
+	MaxWatchErrors                 int               `json:"max_watch_errors"`
+	ProcessIOTimeoutMs             int               `json:"process_io_timeout_ms"`
+	ValidateGoFiles                bool              `json:"validate_go_files"`
+	ValidationDebounceMs           int               `json:"validation_debounce_ms"`
+	WatchDirCreated                bool              `json:"watch_dir_created"`
+	OutputTruncateBytes            int               `json:"output_truncate_bytes"`
+	HotReloadSignal                string            `json:"hot_reload_signal"`
+	HotReloadExtensions            []string          `json:"hot_reload_extensions"`
+	AutoInstallTools               bool              `json:"auto_install_tools"`
+	MinGoVersion                   string            `json:"min_go_version"`
+	GoVersionStrict                bool              `json:"go_version_strict"`
+	BuildMemoryLimit               string            `json:"build_memory_limit"`
+	WatchRemote                    string            `json:"watch_remote"`
+	InjectPulseVars                bool              `json:"inject_pulse_vars"`
+	WatchIgnoreCase                bool              `json:"watch_ignore_case"`
+	TestFailFast                   bool              `json:"test_fail_fast"`
+	TestRace                       bool              `json:"test_race"`
+	ExcludeTestFiles               bool              `json:"exclude_test_files"`
+	CGOFlags                       map[string]string `json:"cgo_flags"`
+	WatchTickerType                string            `json:"watch_ticker_type"`
+	BuildNotifySound               string            `json:"build_notify_sound"`
+	BuildNotifySoundFile           string            `json:"build_notify_sound_file"`
+	WatchViaRsyncChecksum          bool              `json:"watch_via_rsync_checksum"`
+	WatchMigrations                bool              `json:"watch_migrations"`
+	MigrationDir                   string            `json:"migration_dir"`
+	MigrationCommand               string            `json:"migration_command"`
+	TypeScriptCheck                bool              `json:"typescript_check"`
+	TypeScriptBuild                bool              `json:"typescript_build"`
+	TSConfigPath                   string            `json:"tsconfig_path"`
+	ExitOnChildExit                bool              `json:"exit_on_child_exit"`
+	ColorOutputByLevel             bool              `json:"color_output_by_level"`
+	CDPReload                      bool              `json:"reload_browser_on_change"`
+	CDPPort                        int               `json:"cdp_port"`
+	GoListCache                    bool              `json:"go_list_cache"`
+	WatchFunctions                 []string          `json:"watch_specific_functions"`
+	CopyConfigFiles                []string          `json:"copy_config_files"`
+	UseCopySymlinks                bool              `json:"use_copy_symlinks"`
+	ProfileMemory                  bool              `json:"profile_memory"`
+	PProfPort                      int               `json:"pprof_port"`
+	WatchGeneratedFiles            bool              `json:"watch_generated_files"`
+	WatchBackend                   string            `json:"watch_backend"`
+	RunVulnCheck                   bool              `json:"dependency_vulnerability_check"`
+	VulnCheckStrict                bool              `json:"vuln_check_strict"`
+	IgnorePatterns                 []string          `json:"ignore_patterns"`
+	WatchGodocComments             bool              `json:"watch_godoc_comments"`
+	TidyInterval                   string            `json:"watch_tidy_on_interval"`
+	BuildFlags                     []string          `json:"build_flags"`
+	RaceDetector                   bool              `json:"race_detector"`
+	BuildTags                      []string          `json:"build_tags"`
+	BuildTagsAutoDetect            bool              `json:"build_tags_auto_detect"`
+	BuildTagsFile                  string            `json:"watch_build_tags_file"`
+	WatchFileOwnership             bool              `json:"watch_file_ownership"`
+	StopGracePeriod                string            `json:"stop_grace_period"`
+	ReportUnchangedInterval        string            `json:"report_unchanged_interval"`
+	Debounce                       string            `json:"debounce"`
+	AtomicBinaryReplace            bool              `json:"atomic_binary_replace"`
+	WatchExcludeLargerThanKB       int               `json:"watch_exclude_larger_than_kb"`
+	StripDebugSymbols              bool              `json:"binary_strip_debug"`
+	WatchEventsLog                 string            `json:"watch_events_log"`
+	WatchEventsLogMaxMB            float64           `json:"watch_events_log_max_mb"`
+	BuildCommand                   []string          `json:"build_command"`
+	UseGoRunForTests               bool              `json:"use_go_run_for_tests"`
+	PreBuild                       []string          `json:"pre_build"`
+	PostBuild                      []string          `json:"post_build"`
+	HookTimeout                    string            `json:"hook_timeout"`
+	InheritParentEnv               bool              `json:"inherit_parent_env"`
+	BuildTimeout                   string            `json:"build_timeout"`
+	RestartDelay                   string            `json:"restart_delay"`
+	UMask                          string            `json:"umask"`
+	WatchIntervalDisplay           bool              `json:"watch_interval_display"`
+	TrimPath                       bool              `json:"go_build_trimpath"`
+	SkipIdenticalBinary            bool              `json:"skip_identical_binary"`
+	ProcessNiceLevel               int               `json:"process_nice_level"`
+	BuildID                        string            `json:"build_id"`
+	IgnoreBuildErrorsMatching      []string          `json:"ignore_build_errors_matching"`
+	WatchPollOnResume              bool              `json:"watch_poll_on_resume"`
+	ExitOnProcessFailure           bool              `json:"exit_on_process_failure"`
+	AutoRestartOnExit              bool              `json:"auto_restart_on_exit"`
+	MaxRestarts                    int               `json:"max_restarts"`
 }
 
 // Default configuration
 var config = Config{
-	MainFile:      "main.go",
-	BinaryName:    "app",
-	WatchDir:      ".",
-	WatchExts:     []string{".go", ".mod", ".sum"},
-	WatchInterval: "1s",
-	MaxWatchers:   100,
+	MainFile:                       "main.go",
+	BinaryName:                     "app",
+	WatchDir:                       ".",
+	WatchExts:                      []string{".go", ".mod", ".sum"},
+	WatchInterval:                  "1s",
+	MaxWatchers:                    100,
+	WatchGlobDirs:                  []string{},
+	QuietPeriodMs:                  0,
+	WatchMode:                      "run",
+	TestParallel:                   0,
+	ShowFormatIssues:               false,
+	EnvFile:                        "",
+	Env:                            map[string]string{},
+	WatchEnvFile:                   true,
+	WatchConfig:                    true,
+	TestCount:                      1,
+	TagFile:                        "",
+	WatchGoEmbed:                   false,
+	CrashReport:                    false,
+	ArtifactDir:                    ".",
+	OnCrash:                        "",
+	OnFileChange:                   "",
+	RunAsModule:                    false,
+	IntervalUnit:                   "",
+	IntervalValue:                  0,
+	SkipBuildIfOnlyCommentsChanged: false,
+	WatchChangeThreshold:           1,
+	InjectBuildTime:                false,
+	WatchExternalCommand:           "",
+	WatchGitStash:                  false,
+	WatchOnly:                      false,
+	RunCommand:                     []string{},
+	OnReady:                        "",
+	TestShort:                      false,
+	WatchOnlyChangedPackages:       false,
+	RestartOnSuccessOnly:           false,
+	WatchErrorBackoff:              false,
+	MaxWatchErrors:                 10,
+	ProcessIOTimeoutMs:             0,
+	ValidateGoFiles:                false,
+	ValidationDebounceMs:           2000,
+	WatchDirCreated:                false,
+	OutputTruncateBytes:            0,
+	HotReloadSignal:                "",
+	HotReloadExtensions:            []string{},
+	AutoInstallTools:               false,
+	MinGoVersion:                   "",
+	GoVersionStrict:                false,
+	BuildMemoryLimit:               "",
+	WatchRemote:                    "",
+	InjectPulseVars:                true,
+	WatchIgnoreCase:                runtime.GOOS == "windows" || runtime.GOOS == "darwin",
+	TestFailFast:                   false,
+	TestRace:                       false,
+	ExcludeTestFiles:               false,
+	CGOFlags:                       map[string]string{},
+	WatchTickerType:                "monotonic",
+	BuildNotifySound:               "",
+	BuildNotifySoundFile:           "",
+	WatchViaRsyncChecksum:          false,
+	WatchMigrations:                false,
+	MigrationDir:                   "migrations",
+	MigrationCommand:               "",
+	TypeScriptCheck:                false,
+	TypeScriptBuild:                false,
+	TSConfigPath:                   "tsconfig.json",
+	CDPPort:                        9222,
+	PProfPort:                      6060,
+	WatchGeneratedFiles:            true,
+	WatchBackend:                   "auto",
+	StopGracePeriod:                "3s",
+	Debounce:                       "200ms",
+	WatchEventsLogMaxMB:            10,
+	HookTimeout:                    "30s",
+	InheritParentEnv:               true,
+	BuildTimeout:                   "60s",
+	RestartDelay:                   "0s",
+	IgnoreBuildErrorsMatching:      []string{},
+	WatchPollOnResume:              false,
+	ExitOnProcessFailure:           false,
+	AutoRestartOnExit:              false,
+	MaxRestarts:                    0,
+}
+
+var errMaxWatchers = errors.New("Exceeded max watchers limit")
+
+// Module paths used to go install tools that features depend on
+var toolModules = map[string]string{
+	"govulncheck": "golang.org/x/vuln/cmd/govulncheck@latest",
 }
 
 var (
-	errCh   = make(chan error, 1)
-	buildCh = make(chan bool)
-	done    = make(chan bool)
-	cmd     *exec.Cmd
+	errCh     = make(chan error, 1)
+	buildCh   = make(chan bool)
+	restartCh = make(chan bool)
+	reloadCh  = make(chan bool)
+	configCh  = make(chan bool)
+	exitCh    = make(chan processExit)
+	done      = make(chan bool)
+	cmd       *exec.Cmd
+	cmdDone   chan struct{}
+	envValues map[string]string
+
+	lastBuildTime   time.Time
+	buildCount      atomic.Int64
+	restartCount    int
+	autoRestarts    int
+	lastStopTime    time.Time
+	lastBinaryHash  string
+	binaryUnchanged bool
+	lastChangedFile atomic.Value
+
+	// Compiled ignore_build_errors_matching patterns, and whether the last
+	// build failed with an error that matched one of them
+	ignoredBuildErrors []*regexp.Regexp
+	buildErrorIgnored  bool
+
+	tsChecking      atomic.Bool
+	building        atomic.Bool
+	tsBuildPending  atomic.Bool
+	hotReloadSignal os.Signal
+	tickShown       atomic.Bool
+	pprofOverlay    string
+	autoBuildTags   []string
+	commitCache     struct {
+		commit string
+		key    string
+	}
+	vulnCheck struct {
+		last   time.Time
+		passed bool
+	}
+	generatedCache = struct {
+		sync.Mutex
+		files map[string]generatedFile
+	}{files: make(map[string]generatedFile)}
+	largeFiles = make(map[string]bool)
+	// When the build hooks last ran, so that the files they write, such as
+	// go generate output, do not trigger another build
+	hookRuns struct {
+		sync.Mutex
+		running    bool
+		start, end time.Time
+	}
+
+	// The main loop holds configMu while it replaces config with a reloaded
+	// one. Goroutines that read config while the main loop runs hold a read
+	// lock, apart from the watcher, which is stopped during a reload. Others
+	// are passed the values they need when they start.
+	configMu sync.RWMutex
+	// The default config as JSON, so that a reload starts from the defaults
+	configDefaults []byte
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "validate":
+			os.Exit(validate(os.Args[2:]))
+		case "list":
+			os.Exit(listWatched(os.Args[2:]))
+		}
+	}
+
 	versionFlag := flag.Bool("v", false, "Print version information and exit")
 	initFlag := flag.Bool("init", false, "Initialize a new pulse.json configuration file")
+	formatFlag := flag.String("format", "json", "Format of the file created by -init: json or toml")
 	configFlag := flag.String("c", DefaultConfigPath, "Specify the configuration file path")
+	onceFlag := flag.Bool("once", false, "Build and run the program once without watching, and exit with its exit code")
 	flag.Parse()
 
 	if *versionFlag {
-		fmt.Printf("Go Pulse v%s\n", Version)
+		logf("Go Pulse v%s\n", Version)
 		return
 	}
 
 	if *initFlag {
-		data, err := json.MarshalIndent(config, "", "  ")
-		if err != nil {
-			fmt.Printf("Error creating default config: %s\n", err)
+		var data []byte
+		var path string
+		switch *formatFlag {
+		case "json":
+			var err error
+			if data, err = json.MarshalIndent(config, "", "  "); err != nil {
+				errorf("Error creating default config: %s\n", err)
+				return
+			}
+			path = "pulse.json"
+		case "toml":
+			var err error
+			if data, err = encodeTOML(config); err != nil {
+				errorf("Error creating default config: %s\n", err)
+				return
+			}
+			path = "pulse.toml"
+		default:
+			errorf("Unknown config format %q, use json or toml\n", *formatFlag)
 			return
 		}
-		path := "pulse.json"
 		if err := os.WriteFile(path, data, 0644); err != nil {
-			fmt.Printf("Error writing config file: %s\n", err)
+			errorf("Error writing config file: %s\n", err)
 			return
 		}
-		fmt.Printf("Configuration file created at %s\n", path)
+		logf("Configuration file created at %s\n", path)
+		logf("Add %s to your .gitignore if you enable go_list_cache\n", goListCacheFile)
 		return
 	}
 
-	fmt.Println("🚀 Go Pulse started")
+	logln("🚀 Go Pulse started")
+
+	configPath := resolveConfigPath(*configFlag)
+	configDefaults, _ = json.Marshal(config)
+	if _, err := loadConfig(configPath); err != nil {
+		warnf("⚠️ Warning: %s\n", err)
+		logln("   Using default configuration")
+	}
+
+	if config.MinGoVersion != "" && !checkGoVersion() && config.GoVersionStrict {
+		os.Exit(1)
+	}
+
+	if config.ProfileMemory && !config.WatchOnly && config.WatchMode == "run" {
+		var err error
+		if pprofOverlay, err = writePprofOverlay(); err != nil {
+			warnf("⚠️ Warning: Could not set up pprof: %s\n", err)
+		}
+	}
 
-	loadConfig(*configFlag)
+	installTools()
 
-	fmt.Printf("📋 Configuration:\n")
-	fmt.Printf("   Main file:      %s\n", config.MainFile)
-	fmt.Printf("   Binary name:    %s\n", config.BinaryName)
-	fmt.Printf("   Watch dir:      %s\n", config.WatchDir)
-	fmt.Printf("   Watch exts:     %v\n", config.WatchExts)
-	fmt.Printf("   Watch interval: %s\n", config.WatchInterval)
-	fmt.Printf("   Max watchers:   %d\n", config.MaxWatchers)
-	fmt.Println("👀 Watching for file changes...")
+	logf("📋 Configuration:\n")
+	if config.MainPackage != "" {
+		logf("   Main package:   %s\n", config.MainPackage)
+	} else {
+		logf("   Main file:      %s\n", config.MainFile)
+	}
+	logf("   Binary name:    %s\n", config.BinaryName)
+	if len(config.WatchDirs) > 1 {
+		logf("   Watch dirs:     %v\n", config.WatchDirs)
+	} else {
+		logf("   Watch dir:      %s\n", config.WatchDir)
+	}
+	logf("   Watch exts:     %v\n", config.WatchExts)
+	logf("   Watch interval: %s\n", config.WatchInterval)
+	logf("   Max watchers:   %d\n", config.MaxWatchers)
+	logf("   Watch mode:     %s\n", config.WatchMode)
+	if len(config.BuildCommand) > 0 {
+		logf("   Build command:  %v\n", config.BuildCommand)
+	}
+	if len(config.PreBuild) > 0 {
+		logf("   Pre-build:      %v\n", config.PreBuild)
+	}
+	if len(config.PostBuild) > 0 {
+		logf("   Post-build:     %v\n", config.PostBuild)
+	}
+	if len(config.RunCommand) > 0 {
+		logf("   Run command:    %v\n", config.RunCommand)
+	}
+	if config.RunAsModule {
+		logf("   Run as module:  go run %s\n", mainTarget())
+	}
+	if len(config.WatchGlobDirs) > 0 {
+		logf("   Watch glob dirs:%v\n", config.WatchGlobDirs)
+	}
+	if config.QuietPeriodMs > 0 {
+		logf("   Quiet period:   %dms\n", config.QuietPeriodMs)
+	}
+	if config.TestParallel > 0 {
+		logf("   Test parallel:  %d\n", config.TestParallel)
+	}
+	if config.WatchMode == "test" {
+		logf("   Test count:     %d\n", config.TestCount)
+		logf("   Test short:     %t\n", config.TestShort)
+		logf("   Test fail fast: %t\n", config.TestFailFast)
+		logf("   Test race:      %t\n", config.TestRace)
+		logf("   Test go run:    %t\n", config.UseGoRunForTests)
+	}
+	if config.EnvFile != "" {
+		logf("   Env file:       %s\n", config.EnvFile)
+	}
+	if len(config.Env) > 0 {
+		logf("   Env:            %s\n", strings.Join(slices.Sorted(maps.Keys(config.Env)), ", "))
+	}
+	if !config.InheritParentEnv {
+		logf("   Inherit env:    %t\n", config.InheritParentEnv)
+	}
+	if config.TagFile != "" {
+		logf("   Tag file:       %s\n", config.TagFile)
+	}
+	if config.CrashReport {
+		logf("   Crash reports:  %s\n", config.ArtifactDir)
+	}
+	if config.WatchEventsLog != "" {
+		logf("   Events log:     %s\n", config.WatchEventsLog)
+	}
+	if delay, _ := time.ParseDuration(config.RestartDelay); delay > 0 {
+		logf("   Restart delay:  %s\n", delay)
+	}
+	if config.AutoRestartOnExit {
+		if config.MaxRestarts > 0 {
+			logf("   Auto restart:   up to %d times\n", config.MaxRestarts)
+		} else {
+			logf("   Auto restart:   %t\n", config.AutoRestartOnExit)
+		}
+	}
+	if config.UMask != "" {
+		logf("   Umask:          %s\n", config.UMask)
+	}
+	if config.ProcessNiceLevel != 0 {
+		logf("   Nice level:     %d\n", config.ProcessNiceLevel)
+	}
+	if config.WatchExcludeLargerThanKB > 0 {
+		logf("   Max file size:  %d KB\n", config.WatchExcludeLargerThanKB)
+	}
+	if config.WatchPollOnResume {
+		logf("   Poll on resume: %t\n", config.WatchPollOnResume)
+	}
+	if config.WatchChangeThreshold > 1 {
+		logf("   Min changes:    %d\n", config.WatchChangeThreshold)
+	}
+	if len(config.BuildTags) > 0 {
+		logf("   Build tags:     %s\n", strings.Join(config.BuildTags, ","))
+	}
+	if config.BuildTagsAutoDetect {
+		autoBuildTags = detectBuildTags()
+		logf("   Auto tags:      %s\n", strings.Join(autoBuildTags, ","))
+	}
+	if len(config.BuildFlags) > 0 {
+		logf("   Build flags:    %v\n", config.BuildFlags)
+	}
+	if config.StripDebugSymbols {
+		logf("   Strip debug:    %t\n", config.StripDebugSymbols)
+	}
+	if config.TrimPath {
+		logf("   Trim path:      %t\n", config.TrimPath)
+	}
+	if config.BuildID != "" {
+		logf("   Build ID:       %s\n", config.BuildID)
+	}
+	if len(config.IgnoreBuildErrorsMatching) > 0 {
+		logf("   Ignored errors: %v\n", config.IgnoreBuildErrorsMatching)
+		warnf("⚠️ Warning: Build errors matching ignore_build_errors_matching are suppressed and may hide real errors\n")
+	}
+	if len(config.WatchFunctions) > 0 {
+		logf("   Watch funcs:    %v\n", config.WatchFunctions)
+	}
+	if config.TypeScriptCheck || config.TypeScriptBuild {
+		logf("   TS config:      %s\n", config.TSConfigPath)
+	}
+	if !*onceFlag {
+		logln("👀 Watching for file changes...")
+	}
 
 	cancelCtx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	setupSignalHandling(cancelCtx)
 
-	go watchFiles(cancelCtx)
+	// The initial build always compiles the TypeScript project
+	tsBuildPending.Store(true)
+
+	if *onceFlag {
+		exitCode := runOnce()
+		if pprofOverlay != "" {
+			os.RemoveAll(filepath.Dir(pprofOverlay))
+		}
+		os.Exit(exitCode)
+	}
+
+	stopWatching := startWatching(cancelCtx)
+	if config.WatchConfig {
+		go watchConfigFile(cancelCtx, configPath)
+	}
+	if config.TidyInterval != "" {
+		interval, _ := time.ParseDuration(config.TidyInterval)
+		go tidyPeriodically(cancelCtx, interval)
+	}
 
 	// Initial build and run
 	buildAndRun()
@@ -100,230 +572,4504 @@ loop:
 	for {
 		select {
 		case <-buildCh:
-			stopProcess()
-			buildAndRun()
+			// A change gives the program a fresh set of automatic restarts
+			autoRestarts = 0
+			rebuild()
+		case <-restartCh:
+			restartProgram()
+		case <-reloadCh:
+			sendReloadSignal()
+		case <-configCh:
+			// The watcher is stopped while the config changes and started
+			// again with the new one
+			pending := stopWatching()
+			changes, err := reloadConfig(configPath)
+			stopWatching = startWatching(cancelCtx)
+			if err != nil {
+				warnf("⚠️ Warning: %s\n", err)
+				logln("   Keeping the running configuration")
+			}
+			switch {
+			case changes.rebuild || pending == buildCh:
+				rebuild()
+			case changes.restart || pending == restartCh:
+				restartProgram()
+			case pending == reloadCh:
+				sendReloadSignal()
+			}
+		case exit := <-exitCh:
+			// Exits of processes stopped by pulse are expected
+			if exit.cmd == cmd {
+				handleProcessExit(exit)
+				code := exit.cmd.ProcessState.ExitCode()
+				// Processes killed by a signal have no exit code
+				if code < 0 {
+					code = 1
+				}
+				if config.ExitOnChildExit || config.ExitOnProcessFailure && code != 0 {
+					exitCode = code
+					logf("👋 Exiting with code %d\n", exitCode)
+					break loop
+				}
+				if config.AutoRestartOnExit {
+					autoRestart()
+				}
+			}
 		case err := <-errCh:
-			fmt.Printf("❌ %v\n", err)
+			errorf("❌ %v\n", err)
 			exitCode = 1
 			break loop
 		case <-done:
-			fmt.Println("💤 Go Pulse shutting down...")
+			logln("💤 Go Pulse shutting down...")
 			break loop
 		}
 	}
 
 	cancel()
 	stopProcess()
+	if pprofOverlay != "" {
+		os.RemoveAll(filepath.Dir(pprofOverlay))
+	}
 	os.Exit(exitCode)
 }
 
-// Load the configuration. Fallback to defaults if the config file is missing or invalid.
-func loadConfig(configPath string) {
-	fmt.Printf("📄 Loading configuration from: %s\n", configPath)
+// Fall back to a config file in another format when the default pulse.json
+// does not exist.
+func resolveConfigPath(configPath string) string {
+	if configPath != DefaultConfigPath {
+		return configPath
+	}
+	if _, err := os.Stat(configPath); !os.IsNotExist(err) {
+		return configPath
+	}
+	for _, path := range fallbackConfigPaths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return configPath
+}
+
+// Check a config file without running anything, for pulse validate. Every
+// invalid value loadConfig reports is a problem, as are a missing main file
+// and a build_command that is not in PATH. Returns the exit code.
+func validate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	configFlag := flags.String("c", DefaultConfigPath, "Specify the configuration file path")
+	flags.Parse(args)
+
+	configPath := resolveConfigPath(*configFlag)
+	if _, err := os.Stat(configPath); err != nil {
+		errorf("❌ Could not find config file: %s\n", configPath)
+		return 1
+	}
+
+	problems, err := loadConfig(configPath)
+	if err != nil {
+		problems = append(problems, err.Error())
+	} else {
+		if config.MainPackage == "" && !config.WatchOnly && len(config.BuildCommand) == 0 {
+			if _, err := os.Stat(config.MainFile); err != nil {
+				problems = append(problems, fmt.Sprintf("Main file %q does not exist", config.MainFile))
+			}
+		}
+		if len(config.BuildCommand) > 0 {
+			if _, err := exec.LookPath(config.BuildCommand[0]); err != nil {
+				problems = append(problems, fmt.Sprintf("build_command %q was not found in PATH", config.BuildCommand[0]))
+			}
+		}
+	}
+
+	if len(problems) == 0 {
+		successf("✅ %s is valid\n", configPath)
+		return 0
+	}
+	errorf("❌ %s has %d problem(s):\n", configPath, len(problems))
+	for _, problem := range problems {
+		logf("   - %s\n", problem)
+	}
+	return 1
+}
+
+// Print every file the watcher would track with the config, for pulse list,
+// followed by the count. Fails when the files exceed max_watchers. Returns
+// the exit code.
+func listWatched(args []string) int {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	configFlag := flags.String("c", DefaultConfigPath, "Specify the configuration file path")
+	flags.Parse(args)
+
+	if _, err := loadConfig(resolveConfigPath(*configFlag)); err != nil {
+		errorf("❌ %s\n", err)
+		return 1
+	}
+
+	// Collected the way the watcher does, embedded files after the Go files
+	// that embed them
+	files := make(map[string]time.Time)
+	err := walkWatched(func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !isWatched(path, nil) || exceedsSizeLimit(path, info) {
+			return nil
+		}
+		files[path] = info.ModTime()
+		if len(files) > config.MaxWatchers {
+			return fmt.Errorf("%w: %d, exceeded by %s", errMaxWatchers, config.MaxWatchers, path)
+		}
+		return nil
+	})
+	if err == nil && config.WatchGoEmbed {
+		err = trackEmbedded(files, scanEmbeds(files))
+	}
+	if err != nil {
+		errorf("❌ %v\n", err)
+		return 1
+	}
+	for _, path := range slices.Sorted(maps.Keys(files)) {
+		fmt.Println(path)
+	}
+	logf("👀 %d files watched\n", len(files))
+	return 0
+}
+
+// configDecoder fills in the config from a config file in one format and
+// returns the keys the file sets. The values are validated by loadConfig
+// afterwards, whatever the format.
+type configDecoder interface {
+	Decode(data []byte, config *Config) (map[string]bool, error)
+}
+
+type jsonConfigDecoder struct{}
+
+func (jsonConfigDecoder) Decode(data []byte, config *Config) (map[string]bool, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]bool, len(raw))
+	for key := range raw {
+		keys[key] = true
+	}
+	return keys, json.Unmarshal(data, config)
+}
+
+// mapConfigDecoder decodes a format into plain values and converts them to
+// JSON, so that every format shares the json tags of Config. Numbers and
+// booleans given for string options, such as PORT = 8080 in env, are
+// converted to text.
+type mapConfigDecoder func(data []byte) (map[string]any, error)
+
+func (decode mapConfigDecoder) Decode(data []byte, config *Config) (map[string]bool, error) {
+	values, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]bool, len(values))
+	for key, value := range values {
+		keys[key] = true
+		if t := configFieldType(key); t != nil {
+			values[key] = configText(value, t)
+		}
+	}
+	if data, err = json.Marshal(values); err != nil {
+		return nil, err
+	}
+	return keys, json.Unmarshal(data, config)
+}
+
+// Convert the numbers and booleans in a value for a field of type t to text
+// where the field, or the items of its list or map, is a string.
+func configText(value any, t reflect.Type) any {
+	switch value := value.(type) {
+	case bool, int, int64, uint64, float64:
+		if t.Kind() == reflect.String {
+			return fmt.Sprint(value)
+		}
+	case []any:
+		if t.Kind() == reflect.Slice {
+			for i, item := range value {
+				value[i] = configText(item, t.Elem())
+			}
+		}
+	case map[string]any:
+		if t.Kind() == reflect.Map {
+			for key, item := range value {
+				value[key] = configText(item, t.Elem())
+			}
+		}
+	}
+	return value
+}
+
+// Returns the type of the Config field with a json tag, or nil if no option
+// has that name.
+func configFieldType(name string) reflect.Type {
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if tag, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); tag == name {
+			return t.Field(i).Type
+		}
+	}
+	return nil
+}
+
+// Decoders for config files by extension. Files with any other extension are
+// read as JSON.
+var configDecoders = map[string]configDecoder{
+	".toml": mapConfigDecoder(decodeTOML),
+	".yaml": mapConfigDecoder(decodeYAML),
+	".yml":  mapConfigDecoder(decodeYAML),
+}
+
+// Config files used when there is no pulse.json, in order of preference
+var fallbackConfigPaths = []string{"pulse.toml", "pulse.yaml", "pulse.yml"}
+
+// Load the configuration. Fallback to defaults if the config file is missing,
+// and return an error if it cannot be read or parsed. Returns the invalid
+// values that were replaced or ignored, which are also logged as warnings.
+func loadConfig(configPath string) ([]string, error) {
+	// Invalid values are logged as warnings and returned as problems, while
+	// notices about valid values are only logged
+	problems := []string{}
+	invalid := func(format string, args ...any) {
+		problem := fmt.Sprintf(format, args...)
+		warnf("⚠️ Warning: %s\n", problem)
+		problems = append(problems, problem)
+	}
+
+	logf("📄 Loading configuration from: %s\n", configPath)
 
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		return
+		// The defaults are used as they are, apart from watch_dirs, which
+		// follows watch_dir
+		config.WatchDirs = []string{config.WatchDir}
+		output.colorize.Store(colorEnabled())
+		return problems, nil
 	}
 
 	data, err := os.ReadFile(configPath)
 	if err != nil {
-		fmt.Printf("⚠️ Warning: Could not read config file: %s\n", err)
-		fmt.Println("   Using default configuration")
-		return
+		return nil, fmt.Errorf("Could not read config file: %w", err)
 	}
 
-	err = json.Unmarshal(data, &config)
+	decoder, ok := configDecoders[filepath.Ext(configPath)]
+	if !ok {
+		decoder = jsonConfigDecoder{}
+	}
+	keys, err := decoder.Decode(data, &config)
 	if err != nil {
-		fmt.Printf("⚠️ Warning: Could not parse config file: %s\n", err)
-		fmt.Println("   Using default configuration")
-		return
+		return nil, fmt.Errorf("Could not parse config file: %w", err)
 	}
+	// Set before anything else is logged, so that warnings are colored too
+	output.colorize.Store(colorEnabled())
 
 	if config.MainFile == "" {
 		config.MainFile = "main.go"
 	}
+	if config.MainPackage != "" && config.MainFile != "main.go" {
+		invalid("main_file and main_package are both set, using main_package")
+	}
 	if config.BinaryName == "" {
 		config.BinaryName = "app"
 	}
 	if config.WatchDir == "" {
 		config.WatchDir = "."
 	}
+	// use_go_run is another name for run_as_module
+	if config.UseGoRun {
+		config.RunAsModule = true
+	}
+	// watch_dirs replaces watch_dir, which stays the primary directory
+	if len(config.WatchDirs) == 0 {
+		config.WatchDirs = []string{config.WatchDir}
+	} else {
+		config.WatchDir = config.WatchDirs[0]
+	}
+	for _, dir := range config.WatchDirs {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			invalid("Watch dir %q does not exist", dir)
+		}
+	}
 	if len(config.WatchExts) == 0 {
 		config.WatchExts = []string{".go", ".mod", ".sum"}
 	}
+	if config.MaxRestarts < 0 {
+		invalid("Invalid max_restarts, using default of 0")
+		config.MaxRestarts = 0
+	}
+	if config.AutoRestartOnExit && (config.ExitOnChildExit || config.ExitOnProcessFailure) {
+		warnf("⚠️ Warning: auto_restart_on_exit has no effect on exits that end pulse with exit_on_child_exit or exit_on_process_failure\n")
+	}
+	if config.WatchEventsLogMaxMB <= 0 {
+		invalid("Invalid watch_events_log_max_mb, using default of 10")
+		config.WatchEventsLogMaxMB = 10
+	}
+
+	if config.WatchExcludeLargerThanKB < 0 {
+		invalid("Invalid watch_exclude_larger_than_kb, using default of 0")
+		config.WatchExcludeLargerThanKB = 0
+	}
+
 	if config.MaxWatchers < 1 {
-		fmt.Printf("⚠️ Warning: Invalid max_watchers, using default of 100\n")
+		invalid("Invalid max_watchers, using default of 100")
 		config.MaxWatchers = 100
 	} else if config.MaxWatchers > 500 {
-		fmt.Printf("⚠️ Warning: max_watchers cannot exceed 500\n")
+		invalid("max_watchers cannot exceed 500")
 		config.MaxWatchers = 500
 	}
 
-	// Validate and parse the watch interval
-	duration, err := time.ParseDuration(config.WatchInterval)
-	if err != nil || config.WatchInterval == "" {
-		fmt.Println("⚠️ Warning: Invalid watch_interval, using default of 1s")
-		config.WatchInterval = "1s"
-		duration = 1 * time.Second
+	// Drop glob patterns that filepath.Glob would reject
+	validGlobs := []string{}
+	for _, pattern := range config.WatchGlobDirs {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			invalid("Invalid watch_glob_dirs pattern %q, ignoring", pattern)
+			continue
+		}
+		validGlobs = append(validGlobs, pattern)
 	}
+	config.WatchGlobDirs = validGlobs
 
-	// Enforce minimum interval (500ms)
-	minInterval := 500 * time.Millisecond
-	if duration < minInterval {
-		fmt.Printf("⚠️ Warning: Watch interval too short, using minimum of 500ms\n")
-		config.WatchInterval = "500ms"
-		duration = minInterval
+	validIgnores := []string{}
+	for _, pattern := range config.IgnorePatterns {
+		if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil || strings.Trim(pattern, "/") == "" {
+			invalid("Invalid ignore_patterns pattern %q, ignoring", pattern)
+			continue
+		}
+		validIgnores = append(validIgnores, pattern)
 	}
+	config.IgnorePatterns = validIgnores
 
-	// Enforce maximum interval (1 hour)
-	maxInterval := 1 * time.Hour
-	if duration > maxInterval {
-		fmt.Printf("⚠️ Warning: Watch interval too long, using maximum of 1h\n")
-		config.WatchInterval = "1h"
-		duration = maxInterval
+	config.BuildTags = validBuildTags(config.BuildTags)
+
+	if config.ArtifactDir == "" {
+		config.ArtifactDir = "."
 	}
 
-}
+	if len(config.CopyConfigFiles) > 0 && filepath.Dir(config.BinaryName) == "." {
+		warnf("⚠️ Warning: copy_config_files has no effect when the binary is built in the current directory\n")
+		config.CopyConfigFiles = nil
+	}
+	var validCopies []string
+	for _, path := range config.CopyConfigFiles {
+		if !filepath.IsLocal(path) {
+			invalid("Invalid copy_config_files path %q, ignoring", path)
+			continue
+		}
+		validCopies = append(validCopies, path)
+	}
+	config.CopyConfigFiles = validCopies
 
-func setupSignalHandling(ctx context.Context) {
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	if config.QuietPeriodMs < 0 {
+		invalid("Invalid quiet_period_ms, disabling quiet period")
+		config.QuietPeriodMs = 0
+	}
 
-	go func() {
-		select {
-		case <-ctx.Done():
-			return
-		case sig := <-sigCh:
-			fmt.Printf("\n🛑 Received signal: %v\n", sig)
-			done <- true
-			return
-		}
-	}()
-}
+	if config.WatchMode == "" {
+		config.WatchMode = "run"
+	} else if config.WatchMode != "run" && config.WatchMode != "test" {
+		invalid("Invalid watch_mode %q, using default of run", config.WatchMode)
+		config.WatchMode = "run"
+	}
 
-func watchFiles(ctx context.Context) {
+	if config.WatchOnly && len(config.RunCommand) == 0 {
+		invalid("watch_only requires run_command, disabling watch_only")
+		config.WatchOnly = false
+	}
+	if !config.WatchOnly && len(config.RunCommand) > 0 && config.RunAsModule {
+		invalid("run_command replaces go run, disabling run_as_module")
+		config.RunAsModule = false
+	}
 
-	lastModified := make(map[string]time.Time)
+	// Stripped binaries are usually wanted to be reproducible as well
+	if config.StripDebugSymbols {
+		config.TrimPath = true
+	}
 
-	// Get initial file list and modification times
-	err := filepath.Walk(config.WatchDir, func(path string, info os.FileInfo, err error) error {
+	if strings.ContainsAny(config.BuildID, " \t\"'") {
+		invalid("build_id cannot contain spaces or quotes, ignoring build_id")
+		config.BuildID = ""
+	}
+	if strings.Contains(config.BuildID, "{time}") && config.SkipIdenticalBinary {
+		invalid("skip_identical_binary has no effect with {time} in build_id, since every binary differs, disabling skip_identical_binary")
+		config.SkipIdenticalBinary = false
+	}
+	if config.BuildID != "" && !config.TrimPath {
+		warnf("⚠️ Warning: build_id is set without go_build_trimpath, the binary will still contain local paths\n")
+	}
+
+	ignoredBuildErrors = nil
+	validBuildErrors := []string{}
+	for _, pattern := range config.IgnoreBuildErrorsMatching {
+		re, err := regexp.Compile(pattern)
 		if err != nil {
-			return err
+			invalid("Invalid ignore_build_errors_matching pattern %q, ignoring", pattern)
+			continue
 		}
+		ignoredBuildErrors = append(ignoredBuildErrors, re)
+		validBuildErrors = append(validBuildErrors, pattern)
+	}
+	config.IgnoreBuildErrorsMatching = validBuildErrors
 
-		if info.IsDir() || !shouldWatch(path) {
-			return nil
-		}
+	if len(config.BuildCommand) > 0 && config.AtomicBinaryReplace {
+		warnf("⚠️ Warning: atomic_binary_replace has no effect with build_command, disabling atomic_binary_replace\n")
+		config.AtomicBinaryReplace = false
+	}
 
-		lastModified[path] = info.ModTime()
-		if len(lastModified) > config.MaxWatchers {
-			return fmt.Errorf("Exceeded max watchers limit: %d", config.MaxWatchers)
-		}
-		return nil
-	})
+	if !config.InheritParentEnv && config.RunAsModule {
+		warnf("⚠️ Warning: inherit_parent_env has no effect with run_as_module, go run needs the environment of pulse\n")
+	} else if !config.InheritParentEnv {
+		warnf("⚠️ Warning: inherit_parent_env is disabled, most programs need PATH and HOME to be set in env\n")
+	}
 
-	if err != nil {
-		errCh <- err
-		return
+	if config.TestParallel < 0 {
+		invalid("Invalid test_parallel, using the go test default")
+		config.TestParallel = 0
+	} else if config.TestParallel > 0 && config.WatchMode != "test" {
+		warnf("⚠️ Warning: test_parallel has no effect unless watch_mode is \"test\"\n")
 	}
 
-	duration, err := time.ParseDuration(config.WatchInterval)
-	if err != nil {
-		errCh <- fmt.Errorf("Invalid watch interval: %s", config.WatchInterval)
-		return
+	if config.WatchChangeThreshold < 1 {
+		invalid("Invalid watch_change_threshold, using default of 1")
+		config.WatchChangeThreshold = 1
 	}
 
-	ticker := time.NewTicker(duration)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ctx.Done():
-			fmt.Println("🛑 Stopping file watcher...")
-			return
-		case <-ticker.C:
-			changes := false
+	if config.TestShort && config.WatchMode != "test" {
+		warnf("⚠️ Warning: test_short has no effect unless watch_mode is \"test\"\n")
+	}
+	if config.ExcludeTestFiles && config.WatchMode == "test" {
+		warnf("⚠️ Warning: exclude_test_files is ignored when watch_mode is \"test\"\n")
+	}
+	if config.TestFailFast && config.WatchMode != "test" {
+		warnf("⚠️ Warning: test_fail_fast has no effect unless watch_mode is \"test\"\n")
+	}
+	if config.TestRace && config.WatchMode != "test" {
+		warnf("⚠️ Warning: test_race has no effect unless watch_mode is \"test\"\n")
+	} else if config.TestRace {
+		warnf("⚠️ Race detector enabled: test runs will be 5-20x slower\n")
+	}
+	if config.UseGoRunForTests && config.WatchMode != "test" {
+		warnf("⚠️ Warning: use_go_run_for_tests has no effect unless watch_mode is \"test\"\n")
+	} else if config.UseGoRunForTests {
+		warnf("⚠️ Warning: use_go_run_for_tests is non-standard, results come from PASS or FAIL in the program output. go test is recommended\n")
+	}
 
-			err := filepath.Walk(config.WatchDir, func(path string, info os.FileInfo, err error) error {
-				if err != nil {
-					return err
-				}
+	if config.ProcessIOTimeoutMs < 0 {
+		invalid("Invalid process_io_timeout_ms, disabling I/O timeout")
+		config.ProcessIOTimeoutMs = 0
+	}
 
-				if info.IsDir() || !shouldWatch(path) {
-					return nil
-				}
+	if timeout, err := time.ParseDuration(config.BuildTimeout); err != nil || timeout < 0 {
+		invalid("Invalid build_timeout %q, using default of 60s", config.BuildTimeout)
+		config.BuildTimeout = "60s"
+	}
 
-				// Check if file is new or modified
-				modTime := info.ModTime()
-				lastMod, exists := lastModified[path]
+	if timeout, err := time.ParseDuration(config.HookTimeout); err != nil || timeout <= 0 {
+		invalid("Invalid hook_timeout %q, using default of 30s", config.HookTimeout)
+		config.HookTimeout = "30s"
+	}
 
-				if !exists || modTime.After(lastMod) {
-					changes = true
-					lastModified[path] = modTime
-					fmt.Printf("📝 File changed: %s\n", path)
-				}
+	if grace, err := time.ParseDuration(config.StopGracePeriod); err != nil || grace < 0 {
+		invalid("Invalid stop_grace_period %q, using default of 3s", config.StopGracePeriod)
+		config.StopGracePeriod = "3s"
+	}
+
+	if _, err := strconv.ParseUint(config.UMask, 8, 32); config.UMask != "" && err != nil {
+		invalid("Invalid umask %q, it must be octal such as \"022\", inheriting the umask of pulse", config.UMask)
+		config.UMask = ""
+	}
+
+	if config.ProcessNiceLevel < -20 || config.ProcessNiceLevel > 19 {
+		invalid("Invalid process_nice_level, it must be between -20 and 19, using default of 0")
+		config.ProcessNiceLevel = 0
+	}
+
+	if delay, err := time.ParseDuration(config.RestartDelay); err != nil || delay < 0 {
+		invalid("Invalid restart_delay %q, using default of 0s", config.RestartDelay)
+		config.RestartDelay = "0s"
+	}
+
+	if debounce, err := time.ParseDuration(config.Debounce); err != nil || debounce < 0 {
+		invalid("Invalid debounce %q, using default of 200ms", config.Debounce)
+		config.Debounce = "200ms"
+	}
+
+	if config.ReportUnchangedInterval != "" {
+		if interval, err := time.ParseDuration(config.ReportUnchangedInterval); err != nil || interval <= 0 {
+			invalid("Invalid report_unchanged_interval %q, disabling the report", config.ReportUnchangedInterval)
+			config.ReportUnchangedInterval = ""
+		}
+	}
+
+	if config.TidyInterval != "" {
+		if interval, err := time.ParseDuration(config.TidyInterval); err != nil || interval <= 0 {
+			invalid("Invalid watch_tidy_on_interval %q, disabling go mod tidy", config.TidyInterval)
+			config.TidyInterval = ""
+		}
+	}
+
+	if config.PProfPort <= 0 || config.PProfPort > 65535 {
+		invalid("Invalid pprof_port, using default of 6060")
+		config.PProfPort = 6060
+	}
+
+	if config.CDPPort <= 0 || config.CDPPort > 65535 {
+		invalid("Invalid cdp_port, using default of 9222")
+		config.CDPPort = 9222
+	}
+
+	if config.TSConfigPath == "" {
+		config.TSConfigPath = "tsconfig.json"
+	}
+
+	if config.WatchMigrations && config.MigrationCommand == "" {
+		invalid("watch_migrations requires migration_command, disabling watch_migrations")
+		config.WatchMigrations = false
+	}
+
+	switch config.BuildNotifySound {
+	case "", "success", "failure", "both":
+	default:
+		invalid("Invalid build_notify_sound %q, disabling sounds", config.BuildNotifySound)
+		config.BuildNotifySound = ""
+	}
+
+	if config.WatchTickerType == "" {
+		config.WatchTickerType = "monotonic"
+	} else if config.WatchTickerType != "monotonic" && config.WatchTickerType != "wallclock" {
+		invalid("Invalid watch_ticker_type %q, using default of monotonic", config.WatchTickerType)
+		config.WatchTickerType = "monotonic"
+	}
+
+	switch config.WatchBackend {
+	case "":
+		config.WatchBackend = "auto"
+	case "auto", "poll":
+	case "native":
+		if !nativeBackendSupported() {
+			invalid("watch_backend \"native\" cannot be used with watch_external_command, the wallclock ticker, validate_go_files or watch_interval_display, polling instead")
+			config.WatchBackend = "poll"
+		}
+	default:
+		invalid("Invalid watch_backend %q, using default of auto", config.WatchBackend)
+		config.WatchBackend = "auto"
+	}
+
+	for key, value := range config.CGOFlags {
+		switch key {
+		case "CGO_CFLAGS", "CGO_CPPFLAGS", "CGO_CXXFLAGS", "CGO_LDFLAGS", "CGO_FFLAGS":
+		default:
+			invalid("Unknown cgo_flags key %q, ignoring", key)
+			delete(config.CGOFlags, key)
+			continue
+		}
+		if strings.ContainsAny(value, "$`;|&<>(){}") {
+			warnf("⚠️ Warning: cgo_flags %s contains shell metacharacters, which the go tool does not interpret\n", key)
+		}
+	}
+
+	if config.WatchRemote != "" {
+		if host, dir, ok := strings.Cut(config.WatchRemote, ":"); !ok || host == "" || dir == "" {
+			invalid("Invalid watch_remote %q, expected user@host:path", config.WatchRemote)
+			config.WatchRemote = ""
+		}
+	}
+
+	if config.BuildMemoryLimit != "" && !validMemoryLimit(config.BuildMemoryLimit) {
+		invalid("Invalid build_memory_limit %q, not limiting build memory", config.BuildMemoryLimit)
+		config.BuildMemoryLimit = ""
+	}
+
+	hotReloadSignal = nil
+	if config.HotReloadSignal != "" {
+		sig, err := parseSignal(config.HotReloadSignal)
+		if err != nil {
+			invalid("Invalid hot_reload_signal: %s", err)
+			config.HotReloadSignal = ""
+		}
+		hotReloadSignal = sig
+	}
+
+	if config.OutputTruncateBytes < 0 {
+		invalid("Invalid output_truncate_bytes, disabling output limit")
+		config.OutputTruncateBytes = 0
+	}
+
+	if config.ValidationDebounceMs < 0 {
+		invalid("Invalid validation_debounce_ms, using default of 2000")
+		config.ValidationDebounceMs = 2000
+	}
+
+	if config.MaxWatchErrors < 1 {
+		invalid("Invalid max_watch_errors, using default of 10")
+		config.MaxWatchErrors = 10
+	}
+
+	if config.TestCount < 0 {
+		invalid("Invalid test_count, using default of 1")
+		config.TestCount = 1
+	}
+
+	// A structured interval takes precedence over the duration string
+	if config.IntervalUnit != "" || config.IntervalValue != 0 {
+		switch {
+		case config.IntervalUnit != "ms" && config.IntervalUnit != "s" && config.IntervalUnit != "m":
+			invalid("Invalid watch_interval_unit %q, using watch_interval", config.IntervalUnit)
+		case config.IntervalValue <= 0:
+			invalid("Invalid watch_interval_value, using watch_interval")
+		default:
+			if keys["watch_interval"] {
+				warnf("⚠️ Warning: watch_interval is deprecated and overridden by watch_interval_value and watch_interval_unit\n")
+			}
+			config.WatchInterval = strconv.Itoa(config.IntervalValue) + config.IntervalUnit
+		}
+	}
+
+	// Validate and parse the watch interval
+	duration, err := time.ParseDuration(config.WatchInterval)
+	if err != nil || config.WatchInterval == "" {
+		invalid("Invalid watch_interval, using default of 1s")
+		config.WatchInterval = "1s"
+		duration = 1 * time.Second
+	}
+
+	// Enforce minimum interval (500ms)
+	minInterval := 500 * time.Millisecond
+	if duration < minInterval {
+		invalid("Watch interval too short, using minimum of 500ms")
+		config.WatchInterval = "500ms"
+		duration = minInterval
+	}
+
+	// Enforce maximum interval (1 hour)
+	maxInterval := 1 * time.Hour
+	if duration > maxInterval {
+		invalid("Watch interval too long, using maximum of 1h")
+		config.WatchInterval = "1h"
+		duration = maxInterval
+	}
+	return problems, nil
+}
+
+// What a reloaded config needs to take effect
+type configChanges struct {
+	rebuild bool
+	restart bool
+}
+
+// Config keys used only by the watcher, which is started again on every reload
+var watcherConfigKeys = map[string]bool{
+	"watch_dir":                    true,
+	"watch_dirs":                   true,
+	"watch_exts":                   true,
+	"watch_interval":               true,
+	"watch_interval_unit":          true,
+	"watch_interval_value":         true,
+	"max_watchers":                 true,
+	"watch_glob_dirs":              true,
+	"quiet_period_ms":              true,
+	"watch_env_file":               true,
+	"watch_change_threshold":       true,
+	"watch_external_command":       true,
+	"watch_git_stash":              true,
+	"watch_error_backoff":          true,
+	"max_watch_errors":             true,
+	"watch_dir_created":            true,
+	"watch_ignore_case":            true,
+	"watch_ticker_type":            true,
+	"watch_backend":                true,
+	"ignore_patterns":              true,
+	"debounce":                     true,
+	"on_file_change":               true,
+	"watch_exclude_larger_than_kb": true,
+	"watch_events_log":             true,
+	"watch_events_log_max_mb":      true,
+	"watch_interval_display":       true,
+	"watch_poll_on_resume":         true,
+}
+
+// Config keys used when the program starts, which need a restart but no build
+var processConfigKeys = map[string]bool{
+	"env":                true,
+	"env_file":           true,
+	"inherit_parent_env": true,
+	"args":               true,
+	"umask":              true,
+	"process_nice_level": true,
+	"restart_delay":      true,
+}
+
+// Config keys that take effect as soon as the config is loaded
+var loadedConfigKeys = map[string]bool{
+	"color_output_by_level": true,
+}
+
+// Config keys only read when pulse starts
+var startupConfigKeys = map[string]bool{
+	"watch_config":           true,
+	"profile_memory":         true,
+	"watch_tidy_on_interval": true,
+	"build_tags_auto_detect": true,
+}
+
+// Load the config file again, replacing the running config, and report what
+// the changes need to take effect. Changes to any key that is not known to be
+// safe rebuild the program. The running config is kept when the file cannot
+// be read or parsed.
+func reloadConfig(configPath string) (configChanges, error) {
+	var changes configChanges
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	running := config
+	config = Config{}
+	json.Unmarshal(configDefaults, &config)
+	if _, err := loadConfig(configPath); err != nil {
+		config = running
+		output.colorize.Store(colorEnabled())
+		return changes, err
+	}
+
+	before, after := configValues(running), configValues(config)
+	changed := []string{}
+	startup := []string{}
+	for _, key := range slices.Sorted(maps.Keys(after)) {
+		if reflect.DeepEqual(before[key], after[key]) {
+			continue
+		}
+		changed = append(changed, key)
+		switch {
+		case startupConfigKeys[key]:
+			startup = append(startup, key)
+		case loadedConfigKeys[key], watcherConfigKeys[key]:
+		case processConfigKeys[key]:
+			changes.restart = true
+		default:
+			changes.rebuild = true
+		}
+	}
+
+	if len(changed) == 0 {
+		logln("📝 Config unchanged")
+		return changes, nil
+	}
+	logf("📝 Config changed: %s\n", strings.Join(changed, ", "))
+	if len(startup) > 0 {
+		warnf("⚠️ Warning: Restart pulse for changes to %s to take effect\n", strings.Join(startup, ", "))
+	}
+	return changes, nil
+}
+
+// The config as plain values keyed by the json tags of its fields.
+func configValues(c Config) map[string]any {
+	data, _ := json.Marshal(c)
+	values := make(map[string]any)
+	json.Unmarshal(data, &values)
+	return values
+}
+
+func setupSignalHandling(ctx context.Context) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-sigCh:
+			logf("\n🛑 Received signal: %v\n", sig)
+			done <- true
+			return
+		}
+	}()
+}
+
+// Start watching files. The returned function stops the watcher and waits for
+// it to return, and reports the channel of the last change it was sending, if
+// any, so that the change is not lost.
+func startWatching(ctx context.Context) func() chan bool {
+	watchCtx, cancel := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		watchFiles(watchCtx)
+	}()
+
+	return func() chan bool {
+		cancel()
+		var pending chan bool
+		for {
+			select {
+			case <-stopped:
+				return pending
+			case <-buildCh:
+				pending = buildCh
+			case <-restartCh:
+				if pending != buildCh {
+					pending = restartCh
+				}
+			case <-reloadCh:
+				if pending == nil {
+					pending = reloadCh
+				}
+			}
+		}
+	}
+}
+
+// Poll the config file and ask the main loop to reload it when it changes.
+func watchConfigFile(ctx context.Context, path string) {
+	modified := fileModTime(path)
+	for {
+		configMu.RLock()
+		interval, err := time.ParseDuration(config.WatchInterval)
+		configMu.RUnlock()
+		if err != nil {
+			interval = time.Second
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+
+		// A config file that was removed keeps the running config
+		modTime := fileModTime(path)
+		if modTime.IsZero() || modTime.Equal(modified) {
+			continue
+		}
+		modified = modTime
+		logf("📝 Config file changed: %s\n", path)
+		select {
+		case configCh <- true:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func watchFiles(ctx context.Context) {
+	if config.WatchRemote != "" {
+		watchRemote(ctx)
+		return
+	}
+	if config.WatchViaRsyncChecksum {
+		watchRsync(ctx)
+		return
+	}
+
+	lastModified := make(map[string]time.Time)
+	envModified := fileModTime(config.EnvFile)
+	tagsModified := fileModTime(config.BuildTagsFile)
+	stashModified := gitStashModTime()
+	embedPatterns := []string{}
+	fingerprints := make(map[string]string)
+	funcHashes := make(map[string]map[string]string)
+	docs := make(map[string]map[string]docComment)
+	owners := make(map[string]string)
+	// The marker relies on \r to be overwritten, which only works on a terminal
+	showMarker := config.WatchIntervalDisplay && isTerminal(os.Stdout)
+	dirModified := make(map[string]time.Time)
+	// With watch_dir_created, directories whose mtime did not change are not
+	// read again on each walk
+	walk := walkWatched
+	if config.WatchDirCreated && config.WatchExternalCommand == "" {
+		listings := make(map[string]dirListing)
+		walk = func(fn filepath.WalkFunc) error {
+			return walkWatchDirsListed(listings, fn)
+		}
+	}
+
+	// Get initial file list and modification times
+	err := walk(func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() && config.WatchDirCreated {
+			dirModified[path] = info.ModTime()
+		}
+		if info.IsDir() || !isWatched(path, embedPatterns) || exceedsSizeLimit(path, info) {
+			return nil
+		}
+
+		lastModified[path] = info.ModTime()
+		if config.WatchFileOwnership {
+			owners[path] = fileOwner(info)
+		}
+		if len(lastModified) > config.MaxWatchers {
+			return fmt.Errorf("%w: %d", errMaxWatchers, config.MaxWatchers)
+		}
+		return nil
+	})
+
+	if err != nil {
+		errCh <- err
+		return
+	}
+
+	if config.SkipBuildIfOnlyCommentsChanged {
+		for path := range lastModified {
+			if strings.HasSuffix(path, ".go") {
+				fingerprints[path] = goFingerprint(path)
+			}
+		}
+	}
+
+	if len(config.WatchFunctions) > 0 {
+		for path := range lastModified {
+			if strings.HasSuffix(path, ".go") {
+				funcHashes[path] = functionHashes(path)
+			}
+		}
+	}
+
+	if config.WatchGodocComments {
+		for path := range lastModified {
+			if strings.HasSuffix(path, ".go") {
+				docs[path] = docComments(path)
+			}
+		}
+	}
+
+	if config.WatchGoEmbed {
+		embedPatterns = scanEmbeds(lastModified)
+		if err := trackEmbedded(lastModified, embedPatterns); err != nil {
+			errCh <- err
+			return
+		}
+	}
+
+	var deps *depGraph
+	if config.WatchOnlyChangedPackages {
+		if deps, err = loadDepGraph(true); err != nil {
+			warnf("⚠️ Warning: Could not load package dependencies: %s\n", err)
+		}
+	}
+
+	duration, err := time.ParseDuration(config.WatchInterval)
+	if err != nil {
+		errCh <- fmt.Errorf("Invalid watch interval: %s", config.WatchInterval)
+		return
+	}
+
+	var ticker intervalTicker
+	var tickC <-chan time.Time
+	if useNativeBackend() {
+		nativeTicker, err := newNativeTicker(duration, nativeWatchDirs(), skipNativeDir)
+		if err == nil {
+			ticker, tickC = nativeTicker, nativeTicker.C
+		} else if config.WatchBackend == "native" {
+			warnf("⚠️ Warning: Native file watching failed, polling instead: %s\n", err)
+		}
+	}
+	if ticker == nil && config.WatchTickerType == "wallclock" {
+		wallTicker := newWallclockTicker(duration)
+		ticker, tickC = wallTicker, wallTicker.C
+	} else if ticker == nil {
+		monoTicker := time.NewTicker(duration)
+		ticker, tickC = monoTicker, monoTicker.C
+	}
+	var resume *resumeTicker
+	if config.WatchPollOnResume {
+		resume = newResumeTicker(ticker, tickC)
+		ticker, tickC = resume, resume.C
+	}
+	defer ticker.Stop()
+
+	// Walk error tracking for watch_error_backoff
+	interval := duration
+	watchErrors := 0
+	cleanWalks := 0
+
+	// Build delayed by validate_go_files after a syntax error
+	var pendingBuild time.Time
+
+	// Build held back until files stop changing for the debounce window
+	debounce, _ := time.ParseDuration(config.Debounce)
+	debouncing := false
+
+	// Heartbeat for report_unchanged_interval, reset by every change
+	lastChange := time.Now()
+	var heartbeat <-chan time.Time
+	var heartbeatTimer *time.Timer
+	heartbeatInterval, _ := time.ParseDuration(config.ReportUnchangedInterval)
+	if heartbeatInterval > 0 {
+		heartbeatTimer = time.NewTimer(heartbeatInterval)
+		defer heartbeatTimer.Stop()
+		heartbeat = heartbeatTimer.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			logln("🛑 Stopping file watcher...")
+			return
+		case <-heartbeat:
+			logf("💤 Still watching... (last change: %s ago, builds: %d)\n", formatIdle(time.Since(lastChange)), buildCount.Load())
+			heartbeatTimer.Reset(heartbeatInterval)
+		case <-tickC:
+			detected := false
+			changes := false
+			changedFiles := 0
+			goChanged := false
+			commentChanges := false
+			functionChanges := false
+			reloadChanges := false
+			tsChanges := false
+			seen := make(map[string]bool)
+			changedPaths := []string{}
+			fileChanges := []fileChange{}
+
+			// Files changed while the system was asleep may have mtimes older
+			// than the ones recorded before, so any difference is a change
+			resumed := resume != nil && resume.resumed.Swap(false)
+
+			// A stash or stash pop rewrites many files at once, possibly within
+			// the mtime granularity, so every file is treated as changed
+			stashChanged := false
+			if config.WatchGitStash {
+				if modTime := gitStashModTime(); !modTime.Equal(stashModified) {
+					stashChanged = true
+					stashModified = modTime
+					logln("📦 Git stash changed")
+				}
+			}
+
+			err := walk(func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+
+				// A directory mtime changes whenever entries are added to it
+				if info.IsDir() && config.WatchDirCreated {
+					if _, exists := dirModified[path]; !exists {
+						logf("📁 Directory created: %s\n", path)
+					}
+					dirModified[path] = info.ModTime()
+				}
+
+				if info.IsDir() || !isWatched(path, embedPatterns) || exceedsSizeLimit(path, info) {
+					return nil
+				}
+				seen[path] = true
+
+				// Check if file is new or modified
+				modTime := info.ModTime()
+				lastMod, exists := lastModified[path]
+
+				// chown does not touch the mtime, so owners are compared as well
+				ownerChanged := false
+				if config.WatchFileOwnership {
+					owner := fileOwner(info)
+					ownerChanged = exists && owner != owners[path]
+					owners[path] = owner
+				}
+
+				if stashChanged {
+					lastModified[path] = modTime
+				} else if (!exists || modTime.After(lastMod)) && writtenByHooks(modTime) {
+					lastModified[path] = modTime
+				} else if !exists || modTime.After(lastMod) || resumed && !modTime.Equal(lastMod) || ownerChanged {
+					lastModified[path] = modTime
+					lastChangedFile.Store(path)
+					detected = true
+					logf("📝 File changed: %s\n", path)
+					change := fileChange{path: path, kind: "CREATED", before: lastMod, after: modTime}
+					if exists {
+						change.kind = "MODIFIED"
+					}
+					fileChanges = append(fileChanges, change)
+
+					isGo := strings.HasSuffix(path, ".go")
+					if isGo {
+						goChanged = true
+					}
+					if isGo && config.WatchGodocComments {
+						checkDocComments(path, docs)
+					}
+					isTS := isTypeScriptFile(path)
+					if isTS {
+						tsChanges = true
+					}
+
+					switch {
+					case isTS && !config.TypeScriptBuild && !shouldWatch(path):
+						// Without a TypeScript build step the program does not
+						// depend on TypeScript files, only the check runs
+					case isGo && exists && config.SkipBuildIfOnlyCommentsChanged && onlyCommentsChanged(path, fingerprints):
+						commentChanges = true
+					case isGo && exists && len(config.WatchFunctions) > 0 && watchedFunctionsUnchanged(path, funcHashes):
+						functionChanges = true
+					case hotReloadSignal != nil && isHotReloadFile(path):
+						reloadChanges = true
+					default:
+						changes = true
+						changedFiles++
+						changedPaths = append(changedPaths, path)
+					}
+				}
 
 				if !exists {
 					if len(lastModified) >= config.MaxWatchers {
-						return fmt.Errorf("Exceeded max watchers limit: %d", config.MaxWatchers)
+						return fmt.Errorf("%w: %d", errMaxWatchers, config.MaxWatchers)
 					}
 				}
 
-				return nil
-			})
+				return nil
+			})
+
+			if err != nil {
+				if !config.WatchErrorBackoff || errors.Is(err, errMaxWatchers) {
+					errCh <- err
+					return
+				}
+
+				watchErrors++
+				if watchErrors >= config.MaxWatchErrors {
+					errCh <- fmt.Errorf("Too many watch errors, last error: %w", err)
+					return
+				}
+
+				// Poll less often while the file system is misbehaving
+				cleanWalks = 0
+				if interval < 4*duration {
+					interval *= 2
+					ticker.Reset(interval)
+				}
+				warnf("⚠️ Warning: Watch error (%d/%d): %s, polling every %s\n", watchErrors, config.MaxWatchErrors, err, interval)
+			} else if interval != duration {
+				cleanWalks++
+				if cleanWalks >= 3 {
+					interval = duration
+					ticker.Reset(interval)
+					successf("✅ Watching recovered, polling every %s\n", interval)
+				}
+			}
+
+			// Deleted files, or paths that the external command no longer lists,
+			// count as changes. After a failed walk the list is incomplete, so
+			// nothing is removed.
+			if err == nil {
+				for path := range lastModified {
+					if !seen[path] {
+						fileChanges = append(fileChanges, fileChange{path: path, kind: "DELETED", before: lastModified[path]})
+						delete(lastModified, path)
+						lastChangedFile.Store(path)
+						detected = true
+						logf("📝 File removed: %s\n", path)
+						if strings.HasSuffix(path, ".go") {
+							goChanged = true
+						}
+						changes = true
+						changedFiles++
+						changedPaths = append(changedPaths, path)
+					}
+				}
+			}
+
+			if config.WatchEventsLog != "" && len(fileChanges) > 0 {
+				logWatchEvents(fileChanges)
+			}
+			if config.OnFileChange != "" && len(fileChanges) > 0 {
+				runOnFileChange(fileChanges, lastModified)
+			}
+
+			// A changed Go file may have added or removed embed directives
+			if config.WatchGoEmbed && goChanged {
+				embedPatterns = scanEmbeds(lastModified)
+				if err := trackEmbedded(lastModified, embedPatterns); err != nil {
+					errCh <- err
+					return
+				}
+			}
+
+			// The env file only needs a restart, not a rebuild
+			envChanged := false
+			if config.EnvFile != "" && config.WatchEnvFile {
+				if modTime := fileModTime(config.EnvFile); modTime.After(envModified) {
+					envChanged = true
+					detected = true
+					envModified = modTime
+					logf("📝 Env file changed: %s\n", config.EnvFile)
+				}
+			}
+
+			// New build tags need a rebuild whatever else changed
+			tagsChanged := false
+			if config.BuildTagsFile != "" {
+				if modTime := fileModTime(config.BuildTagsFile); !modTime.Equal(tagsModified) {
+					tagsChanged = true
+					detected = true
+					tagsModified = modTime
+					logf("📝 Build tags file changed: %s\n", config.BuildTagsFile)
+				}
+			}
+
+			// Imports may have changed, so refresh the graph before using it
+			if config.WatchOnlyChangedPackages && config.WatchMode == "run" && changes && !stashChanged {
+				if goChanged {
+					if graph, err := loadDepGraph(false); err == nil {
+						deps = graph
+					}
+				}
+				if deps != nil && !deps.affectsMain(changedPaths) {
+					logln("📦 Changed packages are not used by the program, skipping build")
+					changes = false
+				}
+			}
+
+			if tsChanges {
+				tsBuildPending.Store(true)
+				if config.TypeScriptCheck {
+					go checkTypeScript(config.TSConfigPath)
+				}
+			}
+
+			if stashChanged {
+				changes = true
+			} else if changes && changedFiles < config.WatchChangeThreshold {
+				logf("⏳ %d file(s) changed, waiting for at least %d before rebuilding\n", changedFiles, config.WatchChangeThreshold)
+				changes = false
+			}
+
+			// Give the developer a chance to fix syntax errors before building
+			if config.ValidateGoFiles {
+				if changes {
+					if validGoFiles(changedPaths) {
+						pendingBuild = time.Time{}
+					} else {
+						pendingBuild = time.Now().Add(time.Duration(config.ValidationDebounceMs) * time.Millisecond)
+						changes = false
+						// The validation delay replaces the debounce window
+						if debouncing {
+							debouncing = false
+							ticker.Reset(interval)
+						}
+					}
+				} else if !pendingBuild.IsZero() && !time.Now().Before(pendingBuild) {
+					pendingBuild = time.Time{}
+					changes = true
+				}
+			}
+
+			if tagsChanged {
+				changes = true
+			}
+
+			if detected {
+				lastChange = time.Now()
+				if heartbeatTimer != nil {
+					heartbeatTimer.Reset(heartbeatInterval)
+				}
+			}
+
+			if changes && debounce > 0 {
+				// Check again after the debounce window instead of building,
+				// so that files saved together are handled by the same walks
+				// and result in a single build
+				debouncing = true
+				ticker.Reset(debounce)
+			} else if changes || debouncing {
+				if debouncing {
+					debouncing = false
+					ticker.Reset(interval)
+				}
+				buildCh <- true
+			} else {
+				if commentChanges {
+					logln("📝 Only comments changed, skipping build")
+				}
+				if functionChanges {
+					logln("📝 Watched functions unchanged, skipping build")
+				}
+				if envChanged {
+					restartCh <- true
+				} else if reloadChanges {
+					reloadCh <- true
+				}
+			}
+
+			// Overwritten by the next marker, and cleared by the next message
+			if !detected && showMarker {
+				logf("\r· [%s]", time.Now().Format("15:04:05"))
+				tickShown.Store(true)
+			}
+		}
+	}
+}
+
+// Format an idle time for the still watching report, such as 3h 22m.
+func formatIdle(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+	hours, minutes := int(d.Hours()), int(d.Minutes())%60
+	if hours == 0 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh %dm", hours, minutes)
+}
+
+// Poll a remote directory over SSH instead of watching local files. Each poll
+// lists the files changed since the previous one using a sentinel file on the
+// remote host. The build itself still happens locally.
+func watchRemote(ctx context.Context) {
+	host, dir, _ := strings.Cut(config.WatchRemote, ":")
+	sentinel := fmt.Sprintf("/tmp/pulse_lastcheck_%d", os.Getpid())
+
+	names := []string{}
+	for _, ext := range config.WatchExts {
+		names = append(names, "-name "+shellQuote("*"+ext))
+	}
+
+	// Touch the new sentinel before listing so no change falls between polls
+	check := fmt.Sprintf("touch %[1]s.new && find %[2]s -type f \\( %[3]s \\) -newer %[1]s && mv %[1]s.new %[1]s",
+		sentinel, shellQuote(dir), strings.Join(names, " -o "))
+	if err := exec.Command("ssh", "-o", "BatchMode=yes", host, "touch "+sentinel).Run(); err != nil {
+		errCh <- fmt.Errorf("Could not reach %s: %w", host, err)
+		return
+	}
+
+	duration, err := time.ParseDuration(config.WatchInterval)
+	if err != nil {
+		errCh <- fmt.Errorf("Invalid watch interval: %s", config.WatchInterval)
+		return
+	}
+
+	ticker := time.NewTicker(duration)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			logln("🛑 Stopping file watcher...")
+			return
+		case <-ticker.C:
+			out, err := exec.Command("ssh", "-o", "BatchMode=yes", host, check).Output()
+			if err != nil {
+				warnf("⚠️ Warning: Remote check on %s failed: %s\n", host, err)
+				continue
+			}
+
+			changed := strings.Fields(string(out))
+			for _, path := range changed {
+				lastChangedFile.Store(path)
+				logf("📝 File changed: %s:%s\n", host, path)
+			}
+			if len(changed) > 0 {
+				buildCh <- true
+			}
+		}
+	}
+}
+
+// Detect changes by content instead of mtime, for file systems where mtimes
+// are unreliable. rsync keeps a snapshot of the watched files in a temporary
+// directory and, comparing checksums, lists the files that differ from it on
+// every poll.
+func watchRsync(ctx context.Context) {
+	snapshot, err := os.MkdirTemp("", "pulse-rsync-")
+	if err != nil {
+		errCh <- fmt.Errorf("Could not create rsync snapshot dir: %w", err)
+		return
+	}
+	defer os.RemoveAll(snapshot)
+
+	args := []string{"--checksum", "--recursive", "--delete", "--prune-empty-dirs", "--out-format=%n", "--include=*/"}
+	for _, ext := range config.WatchExts {
+		args = append(args, "--include=*"+ext)
+	}
+	args = append(args, "--exclude=*", filepath.Clean(config.WatchDir)+string(filepath.Separator), snapshot)
+
+	// The first sync fills the snapshot, its output is not a change
+	if out, err := exec.Command("rsync", args...).CombinedOutput(); err != nil {
+		errCh <- fmt.Errorf("rsync failed: %w: %s", err, strings.TrimSpace(string(out)))
+		return
+	}
+
+	duration, err := time.ParseDuration(config.WatchInterval)
+	if err != nil {
+		errCh <- fmt.Errorf("Invalid watch interval: %s", config.WatchInterval)
+		return
+	}
+
+	ticker := time.NewTicker(duration)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			logln("🛑 Stopping file watcher...")
+			return
+		case <-ticker.C:
+			out, err := exec.Command("rsync", args...).Output()
+			if err != nil {
+				warnf("⚠️ Warning: rsync failed: %s\n", err)
+				continue
+			}
+
+			changes := false
+			for _, line := range strings.Split(string(out), "\n") {
+				line = strings.TrimPrefix(strings.TrimSpace(line), "deleting ")
+				if line == "" || strings.HasSuffix(line, "/") {
+					continue
+				}
+				path := filepath.Join(config.WatchDir, line)
+				lastChangedFile.Store(path)
+				logf("📝 File changed: %s\n", path)
+				changes = true
+			}
+			if changes {
+				buildCh <- true
+			}
+		}
+	}
+}
+
+// Quote a string for use as a single POSIX shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Quote a string for use as a single PowerShell word. Single quotes, which
+// include the typographic ones in PowerShell, are escaped by doubling them.
+func powerShellQuote(s string) string {
+	r := strings.NewReplacer("'", "''", "\u2018", "\u2018\u2018", "\u2019", "\u2019\u2019", "\u201a", "\u201a\u201a", "\u201b", "\u201b\u201b")
+	return "'" + r.Replace(s) + "'"
+}
+
+// Parse the changed Go files, printing any syntax errors. Reports whether all
+// of them parsed. Deleted files are skipped.
+func validGoFiles(paths []string) bool {
+	valid := true
+	fset := token.NewFileSet()
+	for _, path := range paths {
+		if !strings.HasSuffix(path, ".go") {
+			continue
+		}
+		_, err := parser.ParseFile(fset, path, nil, parser.AllErrors)
+		// Deleted files have nothing left to check
+		if err == nil || errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		valid = false
+		if list, ok := err.(scanner.ErrorList); ok {
+			for _, e := range list {
+				errorf("❌ Syntax error: %s\n", e)
+			}
+		} else {
+			errorf("❌ Syntax error: %s\n", err)
+		}
+	}
+	return valid
+}
+
+// depGraph maps packages to the packages that import them.
+type depGraph struct {
+	packages   map[string]string   // directory to import path
+	importedBy map[string][]string // import path to importing packages
+	main       string              // import path of the program's package
+}
+
+// Load the package graph of the module with go list.
+func loadDepGraph(useCache bool) (*depGraph, error) {
+	out, err := goList(useCache)
+	if err != nil {
+		return nil, err
+	}
+
+	mainDir, err := filepath.Abs(mainPackageDir())
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &depGraph{
+		packages:   make(map[string]string),
+		importedBy: make(map[string][]string),
+	}
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	for decoder.More() {
+		var pkg struct {
+			Dir        string
+			ImportPath string
+			Imports    []string
+			Standard   bool
+		}
+		if err := decoder.Decode(&pkg); err != nil {
+			return nil, err
+		}
+		if pkg.Standard {
+			continue
+		}
+		graph.packages[pkg.Dir] = pkg.ImportPath
+		for _, imp := range pkg.Imports {
+			graph.importedBy[imp] = append(graph.importedBy[imp], pkg.ImportPath)
+		}
+		if pkg.Dir == mainDir {
+			graph.main = pkg.ImportPath
+		}
+	}
+	return graph, nil
+}
+
+// Run go list for all packages and their dependencies. With go_list_cache the
+// output is stored along with a hash of go.mod and go.sum, and useCache
+// allows reusing it while those are unchanged. Fresh output always replaces
+// the cache.
+func goList(useCache bool) ([]byte, error) {
+	if !config.GoListCache {
+		return exec.Command("go", "list", "-e", "-deps", "-json", "./...").Output()
+	}
+
+	hash := sha256.New()
+	for _, file := range []string{"go.mod", "go.sum"} {
+		data, _ := os.ReadFile(file)
+		fmt.Fprintf(hash, "%s\n%d\n", file, len(data))
+		hash.Write(data)
+	}
+	key := hex.EncodeToString(hash.Sum(nil))
+
+	if useCache {
+		if data, err := os.ReadFile(goListCacheFile); err == nil {
+			if cached, ok := bytes.CutPrefix(data, []byte(key+"\n")); ok {
+				return cached, nil
+			}
+		}
+	}
+
+	out, err := exec.Command("go", "list", "-e", "-deps", "-json", "./...").Output()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(goListCacheFile, append([]byte(key+"\n"), out...), 0644); err != nil {
+		warnf("⚠️ Warning: Could not write %s: %s\n", goListCacheFile, err)
+	}
+	return out, nil
+}
+
+// Reports whether any of the changed files can affect the program. Files that
+// are not Go files, or that are outside the known packages, always do.
+func (g *depGraph) affectsMain(paths []string) bool {
+	if g.main == "" {
+		return true
+	}
+
+	for _, path := range paths {
+		if !strings.HasSuffix(path, ".go") {
+			return true
+		}
+		dir, err := filepath.Abs(filepath.Dir(path))
+		if err != nil {
+			return true
+		}
+		pkg, ok := g.packages[dir]
+		if !ok {
+			return true
+		}
+
+		// Walk up the importers of the changed package looking for main
+		visited := map[string]bool{pkg: true}
+		queue := []string{pkg}
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			if current == g.main {
+				return true
+			}
+			for _, importer := range g.importedBy[current] {
+				if !visited[importer] {
+					visited[importer] = true
+					queue = append(queue, importer)
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Reports whether watchFiles should wait for file system events instead of
+// polling.
+func useNativeBackend() bool {
+	switch config.WatchBackend {
+	case "native":
+		return true
+	case "auto":
+		return nativeBackendSupported()
+	}
+	return false
+}
+
+// Reports whether the config can be used with the native backend. The native
+// backend only ticks after events, so the external watch command, the
+// wallclock ticker, the delayed build of validate_go_files and the idle marker
+// of watch_interval_display need polling.
+func nativeBackendSupported() bool {
+	return config.WatchExternalCommand == "" && config.WatchTickerType == "monotonic" &&
+		!config.ValidateGoFiles && !config.WatchIntervalDisplay
+}
+
+// Reports whether the native backend leaves a directory unwatched: ignored
+// directories, and .git and node_modules, which hold too many directories to
+// watch each of them.
+func skipNativeDir(path string) bool {
+	name := filepath.Base(path)
+	return name == ".git" || name == "node_modules" || shouldIgnore(path, true)
+}
+
+// Returns the directories to watch with the native backend: every directory
+// under the watch roots, and the directories of the env file and the build
+// tags file.
+func nativeWatchDirs() []string {
+	dirs := []string{}
+	walkWatchDirs(func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		if skipNativeDir(path) && !slices.Contains(config.WatchDirs, path) {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, path)
+		return nil
+	})
+	if config.EnvFile != "" && config.WatchEnvFile {
+		dirs = append(dirs, filepath.Dir(config.EnvFile))
+	}
+	if config.BuildTagsFile != "" {
+		dirs = append(dirs, filepath.Dir(config.BuildTagsFile))
+	}
+	return dirs
+}
+
+// nativeTicker ticks when files change instead of on a fixed interval. The
+// platform's dirWatcher reports changes, and a tick is delivered one interval
+// after the first of them so that bursts of changes are handled together.
+type nativeTicker struct {
+	C <-chan time.Time
+
+	c        chan time.Time
+	watcher  *dirWatcher
+	mu       sync.Mutex
+	interval time.Duration
+	timer    *time.Timer
+	stopped  bool
+}
+
+// Create a ticker watching dirs. Directories created later are watched as
+// well unless skip reports that they should not be.
+func newNativeTicker(d time.Duration, dirs []string, skip func(dir string) bool) (*nativeTicker, error) {
+	c := make(chan time.Time, 1)
+	t := &nativeTicker{C: c, c: c, interval: d}
+	watcher, err := newDirWatcher(dirs, skip, t.schedule)
+	if err != nil {
+		return nil, err
+	}
+	t.watcher = watcher
+	return t, nil
+}
+
+// Schedule a tick one interval from now unless one is already pending.
+func (t *nativeTicker) schedule() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopped || t.timer != nil {
+		return
+	}
+	t.timer = time.AfterFunc(t.interval, t.fire)
+}
+
+func (t *nativeTicker) fire() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.timer = nil
+	if t.stopped {
+		return
+	}
+	select {
+	case t.c <- time.Now():
+	default:
+	}
+}
+
+// Change the interval and tick once after it, as time.Ticker would, whether
+// or not files change in the meantime.
+func (t *nativeTicker) Reset(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.interval = d
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	if !t.stopped {
+		t.timer = time.AfterFunc(d, t.fire)
+	}
+}
+
+func (t *nativeTicker) Stop() {
+	t.mu.Lock()
+	t.stopped = true
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	t.mu.Unlock()
+
+	// The watcher skips directories based on the config, so it has to finish
+	// before the watcher goroutine returns
+	t.watcher.Close()
+}
+
+// intervalTicker is implemented by time.Ticker, wallclockTicker,
+// nativeTicker and resumeTicker.
+type intervalTicker interface {
+	Reset(d time.Duration)
+	Stop()
+}
+
+// wallclockTicker ticks like time.Ticker, but schedules each tick after the
+// previous one and measures the time between ticks on the wall clock. A gap
+// much longer than the interval means the system was asleep or the clock
+// jumped, which is reported before the tick is delivered.
+type wallclockTicker struct {
+	C <-chan time.Time
+
+	c        chan time.Time
+	mu       sync.Mutex
+	interval time.Duration
+	last     time.Time
+	timer    *time.Timer
+	stopped  bool
+}
+
+func newWallclockTicker(d time.Duration) *wallclockTicker {
+	c := make(chan time.Time, 1)
+	t := &wallclockTicker{C: c, c: c, interval: d, last: time.Now().Round(0)}
+	t.timer = time.AfterFunc(d, t.fire)
+	return t
+}
+
+func (t *wallclockTicker) fire() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopped {
+		return
+	}
+
+	// Round(0) strips the monotonic reading so the difference is wall time
+	now := time.Now().Round(0)
+	if gap := now.Sub(t.last); gap > 2*t.interval {
+		logf("⏰ %s since the last check, checking for changes now\n", gap.Round(time.Second))
+	}
+	t.last = now
+
+	select {
+	case t.c <- now:
+	default:
+	}
+	t.timer = time.AfterFunc(t.interval, t.fire)
+}
+
+func (t *wallclockTicker) Reset(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.interval = d
+	t.timer.Stop()
+	t.timer = time.AfterFunc(d, t.fire)
+}
+
+func (t *wallclockTicker) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped = true
+	t.timer.Stop()
+}
+
+// How far the wall clock has to run ahead of the monotonic clock between two
+// checks before resumeTicker takes it as a resume from sleep
+const resumeClockGap = 5 * time.Second
+
+// resumeTicker passes on the ticks of another ticker, and ticks straight away
+// when the system resumes from sleep. The monotonic clock stops while the
+// system sleeps and the wall clock does not, so a resume shows up as a gap
+// between the two. On Linux the suspend count in /sys/power is checked too.
+type resumeTicker struct {
+	intervalTicker
+	C <-chan time.Time
+
+	// Set when a tick is delivered for a resume, until the watcher reads it
+	resumed atomic.Bool
+	stop    chan struct{}
+}
+
+func newResumeTicker(t intervalTicker, tickC <-chan time.Time) *resumeTicker {
+	c := make(chan time.Time, 1)
+	r := &resumeTicker{intervalTicker: t, C: c, stop: make(chan struct{})}
+	go r.run(tickC, c)
+	return r
+}
+
+func (r *resumeTicker) run(tickC <-chan time.Time, c chan time.Time) {
+	check := time.NewTicker(time.Second)
+	defer check.Stop()
+	last := time.Now()
+	suspends := suspendCount()
+
+	for {
+		var now time.Time
+		select {
+		case <-r.stop:
+			return
+		case now = <-tickC:
+		case now = <-check.C:
+			gap := now.Round(0).Sub(last.Round(0)) - now.Sub(last)
+			count := suspendCount()
+			last = now
+			if gap < resumeClockGap && count == suspends {
+				continue
+			}
+			suspends = count
+			logln("☀️ System resumed from sleep, checking all files now")
+			r.resumed.Store(true)
+		}
+
+		select {
+		case c <- now:
+		default:
+		}
+	}
+}
+
+func (r *resumeTicker) Stop() {
+	r.intervalTicker.Stop()
+	close(r.stop)
+}
+
+// Returns the modification time of the git stash ref, or the zero time if
+// there is no stash.
+func gitStashModTime() time.Time {
+	info, err := os.Stat(filepath.Join(".git", "refs", "stash"))
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// Collect the patterns of the //go:embed directives in the watched Go files.
+// Patterns are joined with the directory of the file that declares them.
+func scanEmbeds(files map[string]time.Time) []string {
+	patterns := []string{}
+
+	for file := range files {
+		if !strings.HasSuffix(file, ".go") {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+
+		dir := filepath.Dir(file)
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if !strings.HasPrefix(line, "//go:embed ") {
+				continue
+			}
+			for _, pattern := range strings.Fields(strings.TrimPrefix(line, "//go:embed ")) {
+				pattern = strings.Trim(pattern, "\"`")
+				pattern = strings.TrimPrefix(pattern, "all:")
+				patterns = append(patterns, filepath.Join(dir, pattern))
+			}
+		}
+	}
+
+	return patterns
+}
+
+// Reports whether a file is embedded, either directly or because one of its
+// parent directories matches an embed pattern.
+func isEmbedded(path string, patterns []string) bool {
+	for _, pattern := range patterns {
+		for p := path; p != "." && p != string(filepath.Separator); p = filepath.Dir(p) {
+			if ok, _ := filepath.Match(pattern, p); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Reports whether the watcher tracks a file: one with a watched extension, an
+// embedded file, or a hot reload, migration or TypeScript file. Files listed
+// by an external command are always watched.
+func isWatched(path string, embedPatterns []string) bool {
+	return config.WatchExternalCommand != "" || shouldWatch(path) || isEmbedded(path, embedPatterns) || isHotReloadFile(path) || isMigrationFile(path) || isTypeScriptFile(path)
+}
+
+// Start tracking embedded files that are not watched yet, without reporting
+// them as changed.
+func trackEmbedded(lastModified map[string]time.Time, patterns []string) error {
+	return walkWatchDirs(func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !isEmbedded(path, patterns) {
+			return nil
+		}
+		if _, exists := lastModified[path]; !exists {
+			lastModified[path] = info.ModTime()
+			if len(lastModified) > config.MaxWatchers {
+				return fmt.Errorf("%w: %d", errMaxWatchers, config.MaxWatchers)
+			}
+		}
+		return nil
+	})
+}
+
+// Compute a fingerprint of a Go file that ignores comments and whitespace.
+// Returns an empty string if the file cannot be read or does not parse.
+func goFingerprint(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	fset := token.NewFileSet()
+	if _, err := parser.ParseFile(fset, path, data, parser.SkipObjectResolution); err != nil {
+		return ""
+	}
+
+	// The token stream without comments is identical exactly when the parsed
+	// files only differ in comments and formatting
+	var sc scanner.Scanner
+	sc.Init(fset.AddFile(path, -1, len(data)), data, nil, 0)
+
+	var b strings.Builder
+	for {
+		_, tok, lit := sc.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON {
+			lit = ";"
+		}
+		b.WriteString(tok.String())
+		b.WriteByte(' ')
+		b.WriteString(lit)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Reports whether only comments changed in a Go file since its last
+// fingerprint, and records the new fingerprint.
+func onlyCommentsChanged(path string, fingerprints map[string]string) bool {
+	fingerprint := goFingerprint(path)
+	previous, ok := fingerprints[path]
+	fingerprints[path] = fingerprint
+	return ok && fingerprint != "" && fingerprint == previous
+}
+
+// Hash the watched functions declared in a Go file, keyed by their name as
+// used in watch_specific_functions: package.Function or package.Type.Method.
+// Comments and formatting do not affect the hashes. Returns nil if the file
+// cannot be read or does not parse.
+func functionHashes(path string) map[string]string {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+
+	hashes := make(map[string]string)
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+
+		name := fn.Name.Name
+		if recv := receiverName(fn); recv != "" {
+			name = recv + "." + name
+		}
+		name = file.Name.Name + "." + name
+		if !slices.Contains(config.WatchFunctions, name) {
+			continue
+		}
+
+		var b bytes.Buffer
+		if err := printer.Fprint(&b, fset, fn); err != nil {
+			return nil
+		}
+		sum := sha256.Sum256(b.Bytes())
+		hashes[name] = hex.EncodeToString(sum[:])
+	}
+	return hashes
+}
+
+// Returns the name of the receiver type of a method, or an empty string for
+// functions.
+func receiverName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return ""
+	}
+	recv := fn.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv = star.X
+	}
+	switch t := recv.(type) {
+	case *ast.IndexExpr:
+		recv = t.X
+	case *ast.IndexListExpr:
+		recv = t.X
+	}
+	if ident, ok := recv.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// docComment records whether an exported declaration has a doc comment.
+type docComment struct {
+	kind       string
+	line       int
+	documented bool
+}
+
+// Find the exported functions, methods and types declared in a Go file, keyed
+// by name. Returns nil if the file cannot be read or does not parse.
+func docComments(path string) map[string]docComment {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, nil, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+
+	docs := make(map[string]docComment)
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if !decl.Name.IsExported() {
+				continue
+			}
+			name, kind := decl.Name.Name, "function"
+			if recv := receiverName(decl); recv != "" {
+				name, kind = recv+"."+name, "method"
+			}
+			docs[name] = docComment{kind: kind, line: fset.Position(decl.Pos()).Line, documented: decl.Doc != nil}
+		case *ast.GenDecl:
+			if decl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				if !typeSpec.Name.IsExported() {
+					continue
+				}
+				// The comment of a type declared on its own belongs to the declaration
+				documented := typeSpec.Doc != nil || decl.Doc != nil && !decl.Lparen.IsValid()
+				docs[typeSpec.Name.Name] = docComment{kind: "type", line: fset.Position(typeSpec.Pos()).Line, documented: documented}
+			}
+		}
+	}
+	return docs
+}
+
+// Warn about exported declarations in a changed Go file that had a doc
+// comment before the change but no longer do, and record the current state.
+// Files that do not parse are checked again once they do.
+func checkDocComments(path string, docs map[string]map[string]docComment) {
+	current := docComments(path)
+	if current == nil {
+		return
+	}
+	previous := docs[path]
+	docs[path] = current
+
+	names := make([]string, 0, len(current))
+	for name := range current {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		doc := current[name]
+		if before, ok := previous[name]; ok && before.documented && !doc.documented {
+			warnf("⚠️ Exported %s %s lost its doc comment in %s:%d\n", doc.kind, name, path, doc.line)
+		}
+	}
+}
+
+// Reports whether a change to a Go file can be skipped because the file
+// declares watched functions and none of them changed, and records the new
+// hashes. Files without watched functions are never skipped.
+func watchedFunctionsUnchanged(path string, funcHashes map[string]map[string]string) bool {
+	hashes := functionHashes(path)
+	previous, ok := funcHashes[path]
+	funcHashes[path] = hashes
+	if !ok || hashes == nil || len(previous) == 0 && len(hashes) == 0 {
+		return false
+	}
+	return maps.Equal(previous, hashes)
+}
+
+// Returns the directories to walk: the existing WatchDirs plus every
+// directory matching WatchGlobDirs. Globs are expanded on each call so that
+// directories created after startup are picked up on the next tick.
+func watchRoots() []string {
+	roots := []string{}
+	seen := map[string]bool{}
+
+	for _, dir := range config.WatchDirs {
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() || seen[filepath.Clean(dir)] {
+			continue
+		}
+		seen[filepath.Clean(dir)] = true
+		roots = append(roots, dir)
+	}
+
+	for _, pattern := range config.WatchGlobDirs {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			continue
+		}
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil || !info.IsDir() {
+				continue
+			}
+			clean := filepath.Clean(match)
+			if seen[clean] {
+				continue
+			}
+			seen[clean] = true
+			roots = append(roots, match)
+		}
+	}
+
+	return roots
+}
+
+// Call fn for every candidate file. The files come from the external watch
+// command when one is configured, and from walking the watch roots otherwise.
+func walkWatched(fn filepath.WalkFunc) error {
+	if config.WatchExternalCommand == "" {
+		return walkWatchDirs(fn)
+	}
+
+	paths, err := runWatchCommand()
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			// Listed paths that do not exist are treated as absent
+			continue
+		}
+		if err := fn(path, info, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run the external watch command and return the paths it prints, one per
+// line. The command must finish within the watch interval.
+func runWatchCommand() ([]string, error) {
+	timeout, err := time.ParseDuration(config.WatchInterval)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid watch interval: %s", config.WatchInterval)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	watchCmd := exec.CommandContext(ctx, "sh", "-c", config.WatchExternalCommand)
+	watchCmd.Stderr = os.Stderr
+	out, err := watchCmd.Output()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("Watch command did not finish within %s", config.WatchInterval)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Watch command failed: %w", err)
+	}
+
+	paths := []string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			paths = append(paths, line)
+		}
+	}
+	return paths, nil
+}
+
+// Walk every watch root, calling fn for each file or directory found.
+// Directories matching ignore_patterns are skipped.
+func walkWatchDirs(fn filepath.WalkFunc) error {
+	for _, root := range watchRoots() {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err == nil && info.IsDir() && path != root && shouldIgnore(path, true) {
+				return filepath.SkipDir
+			}
+			return fn(path, info, err)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dirListing is the entries of a directory when it was last read, along with
+// its mtime then.
+type dirListing struct {
+	modTime  time.Time
+	listedAt time.Time
+	names    []string
+}
+
+// Directories modified less than this long before they were read are read
+// again on the next walk, since further changes within the mtime granularity
+// would not move the mtime.
+const dirListingSettle = 2 * time.Second
+
+// Walk every watch root like walkWatchDirs, but only read the directories
+// whose mtime changed since the last walk. Adding, removing or renaming an
+// entry changes the mtime of its directory, so the entries of the others are
+// taken from listings and only stat'ed for changes. listings is updated, and
+// directories that are gone are removed from it.
+func walkWatchDirsListed(listings map[string]dirListing, fn filepath.WalkFunc) error {
+	seen := make(map[string]bool)
+	for _, root := range watchRoots() {
+		info, err := os.Lstat(root)
+		if err != nil {
+			err = fn(root, nil, err)
+		} else {
+			err = walkListed(root, info, listings, seen, fn)
+		}
+		if err != nil && err != filepath.SkipDir {
+			return err
+		}
+	}
+	for dir := range listings {
+		if !seen[dir] {
+			delete(listings, dir)
+		}
+	}
+	return nil
+}
+
+func walkListed(path string, info os.FileInfo, listings map[string]dirListing, seen map[string]bool, fn filepath.WalkFunc) error {
+	if err := fn(path, info, nil); err != nil || !info.IsDir() {
+		return err
+	}
+	seen[path] = true
+
+	listing, ok := listings[path]
+	if !ok || !listing.modTime.Equal(info.ModTime()) || listing.listedAt.Sub(listing.modTime) < dirListingSettle {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return fn(path, info, err)
+		}
+		listing = dirListing{modTime: info.ModTime(), listedAt: time.Now()}
+		for _, entry := range entries {
+			listing.names = append(listing.names, entry.Name())
+		}
+		listings[path] = listing
+	}
+
+	for _, name := range listing.names {
+		child := filepath.Join(path, name)
+		childInfo, err := os.Lstat(child)
+		if errors.Is(err, os.ErrNotExist) {
+			// Removed since the listing, the next walk reads the directory
+			continue
+		}
+		if err != nil {
+			err = fn(child, nil, err)
+		} else if childInfo.IsDir() && shouldIgnore(child, true) {
+			continue
+		} else {
+			err = walkListed(child, childInfo, listings, seen, fn)
+		}
+		if err != nil && (err != filepath.SkipDir || childInfo == nil || !childInfo.IsDir()) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get a path relative to the watch dir containing it, or the path unchanged
+// when it is outside every watch dir.
+func watchRelPath(filename string) string {
+	for _, dir := range config.WatchDirs {
+		if r, err := filepath.Rel(dir, filename); err == nil && filepath.IsLocal(r) {
+			return r
+		}
+	}
+	return filename
+}
+
+// Reports whether a path matches one of the ignore_patterns. Patterns follow
+// .gitignore rules: they are relative to their watch dir, a pattern without a slash
+// matches a name at any depth, a trailing slash only matches directories, **
+// matches any number of directories, and everything below a matching
+// directory is ignored as well.
+func shouldIgnore(filename string, isDir bool) bool {
+	if len(config.IgnorePatterns) == 0 {
+		return false
+	}
+	parts := strings.Split(filepath.ToSlash(watchRelPath(filename)), "/")
+
+	for _, pattern := range config.IgnorePatterns {
+		dirOnly := strings.HasSuffix(pattern, "/")
+		pattern = strings.Trim(pattern, "/")
+		anchored := strings.Contains(pattern, "/")
+		patternParts := strings.Split(pattern, "/")
+
+		for i := range parts {
+			// Only the last part can be a file
+			if dirOnly && i == len(parts)-1 && !isDir {
+				break
+			}
+			if anchored {
+				if matchParts(patternParts, parts[:i+1]) {
+					return true
+				}
+			} else if matched, _ := path.Match(pattern, parts[i]); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Match path parts against pattern parts, where a ** part matches any number
+// of path parts.
+func matchParts(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchParts(pattern[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	matched, _ := path.Match(pattern[0], parts[0])
+	return matched && matchParts(pattern[1:], parts[1:])
+}
+
+// Reports whether changes to a file are handled by signalling the program
+// instead of restarting it.
+func isHotReloadFile(filename string) bool {
+	for _, ext := range config.HotReloadExtensions {
+		if strings.HasSuffix(filename, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func shouldWatch(path string) bool {
+	// Ignore patterns win over the extension list
+	if shouldIgnore(path, false) {
+		return false
+	}
+	filename := path
+	// Case-insensitive file systems treat Main.GO and main.go as the same file
+	if config.WatchIgnoreCase {
+		filename = strings.ToLower(filename)
+	}
+	if !config.WatchGeneratedFiles && strings.HasSuffix(filename, ".go") && isGenerated(path) {
+		return false
+	}
+	// Test files are not part of the program binary
+	if config.ExcludeTestFiles && config.WatchMode != "test" && strings.HasSuffix(filename, "_test.go") {
+		return false
+	}
+	for _, ext := range config.WatchExts {
+		if config.WatchIgnoreCase {
+			ext = strings.ToLower(ext)
+		}
+		if strings.HasSuffix(filename, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// generatedFile caches whether a Go file is generated.
+type generatedFile struct {
+	modTime   time.Time
+	generated bool
+}
+
+// Reports whether a Go file has a "// Code generated ... DO NOT EDIT." comment
+// before its package clause. The result is cached until the file changes.
+func isGenerated(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+
+	generatedCache.Lock()
+	defer generatedCache.Unlock()
+	if cached, ok := generatedCache.files[path]; ok && cached.modTime.Equal(info.ModTime()) {
+		return cached.generated
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	generated := false
+	lines := bufio.NewScanner(file)
+	for lines.Scan() {
+		line := strings.TrimSpace(lines.Text())
+		if strings.HasPrefix(line, "package ") {
+			break
+		}
+		if strings.HasPrefix(line, "// Code generated ") && strings.HasSuffix(line, " DO NOT EDIT.") {
+			generated = true
+			break
+		}
+	}
+	generatedCache.files[path] = generatedFile{modTime: info.ModTime(), generated: generated}
+	return generated
+}
+
+// Reports whether a file is larger than watch_exclude_larger_than_kb, logging
+// it the first time it is skipped.
+func exceedsSizeLimit(path string, info os.FileInfo) bool {
+	if config.WatchExcludeLargerThanKB == 0 || info.Size() <= int64(config.WatchExcludeLargerThanKB)*1024 {
+		delete(largeFiles, path)
+		return false
+	}
+	if !largeFiles[path] {
+		largeFiles[path] = true
+		logf("⏭️ [skipped: %s is %.1fMB, exceeds watch_exclude_larger_than_kb]\n", path, float64(info.Size())/(1024*1024))
+	}
+	return true
+}
+
+func buildAndRun() {
+	// Nothing is built in watch only mode, the run command is all there is
+	if config.WatchOnly {
+		runProgram()
+		return
+	}
+
+	if config.WatchMode == "test" {
+		runTests()
+		return
+	}
+
+	// go run builds and runs in one step, so there is nothing to build here
+	if config.RunAsModule {
+		lastBuildTime = time.Now()
+		buildCount.Add(1)
+		runProgram()
+		return
+	}
+
+	if buildProgram() {
+		runProgram()
+	}
+}
+
+// Build and run the program once for -once, without watching files. Returns
+// the exit code of the program, or 1 if it could not be built or started or
+// pulse was stopped first. In test mode the tests are run and 1 means they
+// failed.
+func runOnce() int {
+	if config.WatchMode == "test" && !config.WatchOnly {
+		if runTests() {
+			return 0
+		}
+		return 1
+	}
+
+	buildAndRun()
+	if cmd == nil {
+		return 1
+	}
+
+	select {
+	case exit := <-exitCh:
+		handleProcessExit(exit)
+		exitCode := exit.cmd.ProcessState.ExitCode()
+		// Processes killed by a signal have no exit code
+		if exitCode < 0 {
+			exitCode = 1
+		}
+		return exitCode
+	case <-done:
+		logln("💤 Go Pulse shutting down...")
+		stopProcess()
+		return 1
+	}
+}
+
+// Build and run the program again after it exited on its own, for
+// auto_restart_on_exit, waiting restart_delay first. Once max_restarts
+// restarts have been used up, pulse gives up through errCh.
+func autoRestart() {
+	if config.MaxRestarts > 0 && autoRestarts >= config.MaxRestarts {
+		select {
+		case errCh <- fmt.Errorf("Program exited after %d automatic restarts, giving up", autoRestarts):
+		default:
+		}
+		return
+	}
+	autoRestarts++
+
+	if delay, _ := time.ParseDuration(config.RestartDelay); delay > 0 {
+		time.Sleep(delay)
+	}
+	if config.MaxRestarts > 0 {
+		logf("🔁 Restarting the program (%d/%d)\n", autoRestarts, config.MaxRestarts)
+	} else {
+		logf("🔁 Restarting the program (%d)\n", autoRestarts)
+	}
+	buildAndRun()
+}
+
+// Restart the program without building it, or run the tests again in test mode.
+func restartProgram() {
+	stopProcess()
+	if config.WatchMode == "test" && !config.WatchOnly {
+		buildAndRun()
+	} else {
+		runProgram()
+	}
+}
+
+// Rebuild after a change. The running process is normally stopped first, but
+// with restart_on_success_only it keeps running until a build succeeds. With
+// skip_identical_binary it keeps running while the build is compared against
+// the previous one, and is left alone when the binary is the same. With
+// ignore_build_errors_matching it also keeps running when a build fails with
+// an ignored error.
+func rebuild() {
+	buildFirst := config.RestartOnSuccessOnly || config.SkipIdenticalBinary || len(ignoredBuildErrors) > 0
+	if !buildFirst || config.WatchOnly || config.RunAsModule || config.WatchMode == "test" {
+		stopProcess()
+		buildAndRun()
+		return
+	}
+
+	if buildProgram() {
+		if config.SkipIdenticalBinary && binaryUnchanged && cmd != nil {
+			logln("🔄 Binary unchanged, skipping restart.")
+			return
+		}
+		stopProcess()
+		runProgram()
+	} else if (config.RestartOnSuccessOnly || buildErrorIgnored) && cmd != nil {
+		logln("♻️ Keeping the previous process running")
+	} else {
+		stopProcess()
+	}
+}
+
+// Build the program binary, reporting whether the build succeeded.
+func buildProgram() bool {
+	building.Store(true)
+	defer building.Store(false)
+	buildErrorIgnored = false
+
+	if config.WatchMigrations && !runMigrations() {
+		notifySound(false)
+		return false
+	}
+
+	if config.RunVulnCheck && !runVulnCheck() {
+		notifySound(false)
+		return false
+	}
+
+	logln("🔨 Building...")
+
+	if !runBuildHooks("pre_build", config.PreBuild) {
+		notifySound(false)
+		return false
+	}
+
+	// The program may embed the compiled frontend, so it has to be built first
+	if config.TypeScriptBuild && tsBuildPending.Swap(false) && !buildTypeScript() {
+		tsBuildPending.Store(true)
+		notifySound(false)
+		return false
+	}
+
+	if config.TagFile != "" {
+		if err := buildTaggedPackages(); err != nil {
+			errorf("❌ Build failed: %s\n", err)
+			notifySound(false)
+			return false
+		}
+	}
+
+	// The old binary is removed before building to avoid "text file busy"
+	// errors, along with one left over from an interrupted build. A running
+	// program keeps the file it was started from.
+	if config.AtomicBinaryReplace {
+		os.Remove(config.BinaryName)
+		os.Remove(buildOutputPath())
+	}
+
+	// Build the program, giving up after build_timeout so that a hung build
+	// does not block later rebuilds
+	ctx := context.Background()
+	timeout, _ := time.ParseDuration(config.BuildTimeout)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	buildCmd := buildCommand(ctx)
+	buildCmd.Env = buildEnv()
+	buildCmd.Stderr = os.Stderr
+	if timeout > 0 {
+		// The compiler and linker run as children of go build and keep its
+		// output open, so the whole process group is killed and Run stops
+		// waiting on the output shortly after
+		setProcessGroup(buildCmd)
+		buildCmd.Cancel = func() error { return killProcessGroup(buildCmd) }
+		buildCmd.WaitDelay = time.Second
+	}
+
+	// Check formatting alongside the build so a failure doesn't wait on gofmt
+	var formatCh chan []string
+	var buildOutput bytes.Buffer
+	if config.ShowFormatIssues {
+		formatCh = make(chan []string, 1)
+		roots := watchRoots()
+		go func() {
+			formatCh <- unformattedFiles(roots)
+		}()
+	}
+	// Build errors are held back until they have been checked against
+	// ignore_build_errors_matching
+	if config.ShowFormatIssues || len(ignoredBuildErrors) > 0 {
+		buildCmd.Stderr = &buildOutput
+	}
+
+	if err := buildCmd.Run(); err != nil {
+		if re := matchIgnoredBuildError(buildOutput.String()); re != nil && ctx.Err() == nil {
+			warnf("⚠️ Build error suppressed, it matches ignore_build_errors_matching %q\n", re.String())
+			buildErrorIgnored = true
+			return false
+		}
+		if formatCh != nil {
+			if files := <-formatCh; len(files) > 0 {
+				warnf("⚠️ Formatting issues in: %s\n", strings.Join(files, ", "))
+			}
+		}
+		os.Stderr.Write(buildOutput.Bytes())
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("timed out after %s", timeout)
+		}
+		errorf("❌ Build failed: %s\n", err)
+		notifySound(false)
+		return false
+	}
+	os.Stderr.Write(buildOutput.Bytes())
+
+	if config.AtomicBinaryReplace {
+		if err := os.Rename(buildOutputPath(), config.BinaryName); err != nil {
+			errorf("❌ Could not replace binary: %s\n", err)
+			notifySound(false)
+			return false
+		}
+	}
+
+	if config.SkipIdenticalBinary {
+		hash := binaryHash()
+		binaryUnchanged = hash != "" && hash == lastBinaryHash
+		lastBinaryHash = hash
+	}
+
+	successf("✅ Build successful\n")
+	lastBuildTime = time.Now()
+	buildCount.Add(1)
+	notifySound(true)
+	runBuildHooks("post_build", config.PostBuild)
+	return true
+}
+
+// Return the first ignore_build_errors_matching pattern that matches the
+// build output, or nil if none does.
+func matchIgnoredBuildError(output string) *regexp.Regexp {
+	if output == "" {
+		return nil
+	}
+	for _, re := range ignoredBuildErrors {
+		if re.MatchString(output) {
+			return re
+		}
+	}
+	return nil
+}
+
+// Run the pre_build or post_build commands in order, each with sh -c and
+// limited to hook_timeout. Stops at the first failure and reports whether all
+// of them succeeded.
+func runBuildHooks(name string, commands []string) bool {
+	if len(commands) == 0 {
+		return true
+	}
+	// File mtimes come from a coarser clock, which can lag behind
+	hookRuns.Lock()
+	hookRuns.running, hookRuns.start = true, time.Now().Add(-10*time.Millisecond)
+	hookRuns.Unlock()
+	defer func() {
+		hookRuns.Lock()
+		hookRuns.running, hookRuns.end = false, time.Now()
+		hookRuns.Unlock()
+	}()
+
+	timeout, _ := time.ParseDuration(config.HookTimeout)
+	for _, command := range commands {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		hookCmd := exec.CommandContext(ctx, "sh", "-c", command)
+		hookCmd.Env = buildEnv()
+		hookCmd.Stdout = os.Stdout
+		hookCmd.Stderr = os.Stderr
+		err := hookCmd.Run()
+		if ctx.Err() != nil {
+			err = fmt.Errorf("timed out after %s", timeout)
+		}
+		cancel()
+		if err != nil {
+			warnf("⚠️ Warning: %s command %q failed: %s\n", name, command, err)
+			return false
+		}
+	}
+	return true
+}
+
+// Reports whether a file modified at modTime was written by the build hooks,
+// because it was modified since they started and they are still running or
+// had not yet finished.
+func writtenByHooks(modTime time.Time) bool {
+	hookRuns.Lock()
+	defer hookRuns.Unlock()
+	if modTime.Before(hookRuns.start) {
+		return false
+	}
+	return hookRuns.running || !modTime.After(hookRuns.end)
+}
+
+// Returns the SHA-256 of the built binary, or an empty string if it cannot be
+// read.
+func binaryHash() string {
+	file, err := os.Open(config.BinaryName)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Play a sound for a build result if build_notify_sound asks for it. The
+// sound plays in the background and any failure is ignored.
+func notifySound(success bool) {
+	switch config.BuildNotifySound {
+	case "both":
+	case "success":
+		if !success {
+			return
+		}
+	case "failure":
+		if success {
+			return
+		}
+	default:
+		return
+	}
+
+	file := config.BuildNotifySoundFile
+	var commands [][]string
+	switch runtime.GOOS {
+	case "darwin":
+		if file == "" {
+			file = "/System/Library/Sounds/Glass.aiff"
+			if !success {
+				file = "/System/Library/Sounds/Basso.aiff"
+			}
+		}
+		commands = [][]string{{"afplay", file}}
+	case "windows":
+		script := "[System.Media.SystemSounds]::Beep.Play()"
+		if file != "" {
+			script = "(New-Object Media.SoundPlayer " + powerShellQuote(file) + ").PlaySync()"
+		}
+		commands = [][]string{{"powershell", "-NoProfile", "-Command", script}}
+	default:
+		if file == "" {
+			file = "/usr/share/sounds/freedesktop/stereo/complete.oga"
+			if !success {
+				file = "/usr/share/sounds/freedesktop/stereo/dialog-error.oga"
+			}
+		}
+		commands = [][]string{{"paplay", file}, {"aplay", "-q", file}}
+	}
+
+	go func() {
+		for _, args := range commands {
+			if exec.Command(args[0], args[1:]...).Run() == nil {
+				return
+			}
+		}
+	}()
+}
+
+// Run the compiled program
+func runProgram() {
+	logln("🚀 Running program...")
+
+	if config.WatchOnly {
+		cmd = exec.Command(config.RunCommand[0], append(slices.Clone(config.RunCommand[1:]), programArgs()...)...)
+	} else if len(config.RunCommand) > 0 {
+		// Only the command itself is stopped, anything it starts has to exit
+		// on SIGTERM within stop_grace_period
+		copyConfigFiles()
+		cmd = exec.Command(config.RunCommand[0], append(slices.Clone(config.RunCommand[1:]), programArgs()...)...)
+	} else if config.RunAsModule {
+		args := append(append([]string{"run"}, buildFlags()...), mainFiles()...)
+		cmd = exec.Command("go", append(args, programArgs()...)...)
+		setProcessGroup(cmd)
+	} else {
+		copyConfigFiles()
+		cmd = exec.Command("./"+config.BinaryName, programArgs()...)
+	}
+	cmd.Env = processEnv()
+	restartCount++
+
+	stdout, stderr := io.Writer(os.Stdout), io.Writer(os.Stderr)
+	if config.WatchIntervalDisplay && isTerminal(os.Stdout) {
+		stdout = markerWriter{w: stdout}
+		stderr = markerWriter{w: stderr}
+	}
+	if config.OutputTruncateBytes > 0 {
+		limit := &outputLimit{max: int64(config.OutputTruncateBytes)}
+		stdout = &limitedWriter{w: stdout, limit: limit}
+		stderr = &limitedWriter{w: stderr, limit: limit}
+	}
+	if config.QuietPeriodMs > 0 {
+		until := time.Now().Add(time.Duration(config.QuietPeriodMs) * time.Millisecond)
+		stdout = &quietWriter{w: stdout, until: until}
+		stderr = &quietWriter{w: stderr, until: until}
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	var tail *tailWriter
+	if config.CrashReport {
+		tail = &tailWriter{max: crashReportLines}
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, tail)
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, tail)
+	}
+
+	var activity *activityWriter
+	if config.ProcessIOTimeoutMs > 0 {
+		activity = &activityWriter{w: cmd.Stdout}
+		activity.lastWrite.Store(time.Now().UnixNano())
+		cmd.Stdout = activity
+	}
+
+	// Give the stopped process time to release its ports. Time spent building
+	// counts towards the delay.
+	delay, _ := time.ParseDuration(config.RestartDelay)
+	if wait := delay - time.Since(lastStopTime); wait > 0 {
+		time.Sleep(wait)
+	}
+
+	if config.UMask != "" {
+		mask, _ := strconv.ParseUint(config.UMask, 8, 32)
+		if err := setUmask(cmd, int(mask)); err != nil {
+			warnf("⚠️ Warning: Could not set umask: %s\n", err)
+		}
+	}
+	if err := cmd.Start(); err != nil {
+		errorf("❌ Error starting program: %s\n", err)
+		cmd = nil
+		return
+	}
+
+	if config.ProcessNiceLevel != 0 {
+		if err := setNiceLevel(cmd.Process.Pid, config.ProcessNiceLevel); err != nil {
+			warnf("⚠️ Warning: Could not set process_nice_level: %s\n", err)
+		}
+	}
+
+	// Wait for the process in the background so that exits which pulse did
+	// not cause can be reported
+	proc, procDone := cmd, make(chan struct{})
+	cmdDone = procDone
+	go func() {
+		err := proc.Wait()
+		close(procDone)
+		exitCh <- processExit{cmd: proc, err: err, tail: tail}
+	}()
+
+	if activity != nil {
+		go watchActivity(proc, procDone, activity, time.Duration(config.ProcessIOTimeoutMs)*time.Millisecond)
+	}
+
+	successf("✅ Program is running...\n")
+
+	if pprofOverlay != "" {
+		logf("📈 pprof: http://localhost:%d/debug/pprof/\n", config.PProfPort)
+	}
+
+	if config.CDPReload {
+		go reloadBrowser(config.CDPPort, programPort(proc), procDone)
+	}
+
+	if config.OnReady != "" {
+		go runOnReady(proc, config.OnReady, config.BinaryName)
+	}
+}
+
+// Longest time reloadBrowser waits for the program to listen on its port
+const browserReloadWait = 10 * time.Second
+
+// Reload the most recently active browser tab through the Chrome DevTools
+// Protocol once the program accepts connections on appPort, so that the page
+// is not reloaded against a server that is still starting. Without a port the
+// tab is reloaded straight away. The browser must be started with
+// --remote-debugging-port. Failures are reported as warnings.
+func reloadBrowser(port int, appPort string, done <-chan struct{}) {
+	if appPort != "" {
+		deadline := time.Now().Add(browserReloadWait)
+		for {
+			conn, err := net.DialTimeout("tcp", net.JoinHostPort("localhost", appPort), time.Second)
+			if err == nil {
+				conn.Close()
+				break
+			}
+			if time.Now().After(deadline) {
+				warnf("⚠️ Warning: Program is not listening on port %s, reloading the browser anyway\n", appPort)
+				break
+			}
+			select {
+			case <-done:
+				return
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}
+	if err := cdpReload(port); err != nil {
+		warnf("⚠️ Warning: browser reload failed: %s\n", err)
+		return
+	}
+	logln("🌐 Browser reloaded")
+}
+
+// Send Page.reload to the first page target of the browser listening on the
+// given debugging port. A new connection is made for every reload.
+func cdpReload(port int) error {
+	client := http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(fmt.Sprintf("http://localhost:%d/json", port))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var targets []struct {
+		Type                 string `json:"type"`
+		WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&targets); err != nil {
+		return fmt.Errorf("reading targets: %w", err)
+	}
+
+	wsURL := ""
+	for _, target := range targets {
+		if target.Type == "page" && target.WebSocketDebuggerURL != "" {
+			wsURL = target.WebSocketDebuggerURL
+			break
+		}
+	}
+	if wsURL == "" {
+		return errors.New("no open page")
+	}
+
+	u, err := url.Parse(wsURL)
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialTimeout("tcp", u.Host, 2*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	key := make([]byte, 16)
+	rand.Read(key)
+	fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n\r\n",
+		u.RequestURI(), u.Host, base64.StdEncoding.EncodeToString(key))
+
+	reader := bufio.NewReader(conn)
+	handshake, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		return err
+	}
+	handshake.Body.Close()
+	if handshake.StatusCode != http.StatusSwitchingProtocols {
+		return fmt.Errorf("websocket handshake failed: %s", handshake.Status)
+	}
+
+	if _, err := conn.Write(wsFrame(0x1, []byte(`{"id":1,"method":"Page.reload"}`))); err != nil {
+		return err
+	}
+	// Wait for the reply so the command is handled before disconnecting
+	if _, err := reader.ReadByte(); err != nil {
+		return err
+	}
+	conn.Write(wsFrame(0x8, nil))
+	return nil
+}
+
+// Encode a masked client WebSocket frame. Payloads of 126 bytes or more have
+// their length in the 2 or 8 bytes after the length byte.
+func wsFrame(opcode byte, payload []byte) []byte {
+	frame := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xffff:
+		frame = binary.BigEndian.AppendUint16(append(frame, 0x80|126), uint16(n))
+	default:
+		frame = binary.BigEndian.AppendUint64(append(frame, 0x80|127), uint64(n))
+	}
+	mask := make([]byte, 4)
+	rand.Read(mask)
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	return frame
+}
+
+// Returns the arguments for the program, with $PULSE_RESTART_COUNT replaced
+// by the number of times it has been restarted.
+func programArgs() []string {
+	args := make([]string, len(config.Args))
+	for i, arg := range config.Args {
+		args[i] = strings.ReplaceAll(arg, "$PULSE_RESTART_COUNT", strconv.Itoa(restartCount))
+	}
+	return args
+}
+
+// Returns the PORT variable of a started process, or an empty string if it
+// has none.
+func programPort(proc *exec.Cmd) string {
+	if proc.Env == nil {
+		return os.Getenv("PORT")
+	}
+	port := ""
+	for _, kv := range proc.Env {
+		if value, ok := strings.CutPrefix(kv, "PORT="); ok {
+			port = value
+		}
+	}
+	return port
+}
+
+// Run the on_ready command for a started process. Its failure is reported but
+// does not affect the process.
+func runOnReady(proc *exec.Cmd, command, binary string) {
+	port := programPort(proc)
+
+	readyCmd := exec.Command("sh", "-c", command)
+	readyCmd.Env = append(os.Environ(),
+		"PULSE_BINARY="+binary,
+		"PULSE_PORT="+port,
+		"PULSE_PID="+strconv.Itoa(proc.Process.Pid),
+	)
+	readyCmd.Stdout = os.Stdout
+	readyCmd.Stderr = os.Stderr
+	if err := readyCmd.Run(); err != nil {
+		warnf("⚠️ Warning: on_ready command failed: %s\n", err)
+	}
+}
+
+// fileChange is a change to a single watched file.
+type fileChange struct {
+	path   string
+	kind   string // CREATED, MODIFIED or DELETED
+	before time.Time
+	after  time.Time
+}
+
+// Number of rotated watch_events_log files kept, as .1 to .5
+const watchEventsLogBackups = 5
+
+// Append changes to the watch_events_log as JSON lines, rotating the log once
+// it reaches watch_events_log_max_mb.
+func logWatchEvents(changes []fileChange) {
+	var buf bytes.Buffer
+	now := time.Now()
+	for _, change := range changes {
+		event := struct {
+			Time        time.Time `json:"time"`
+			Path        string    `json:"path"`
+			Event       string    `json:"event"`
+			MtimeBefore time.Time `json:"mtime_before,omitzero"`
+			MtimeAfter  time.Time `json:"mtime_after,omitzero"`
+		}{now, change.path, change.kind, change.before, change.after}
+		data, _ := json.Marshal(event)
+		buf.Write(append(data, '\n'))
+	}
+
+	limit := int64(config.WatchEventsLogMaxMB * 1024 * 1024)
+	if info, err := os.Stat(config.WatchEventsLog); err == nil && info.Size()+int64(buf.Len()) > limit {
+		for i := watchEventsLogBackups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", config.WatchEventsLog, i), fmt.Sprintf("%s.%d", config.WatchEventsLog, i+1))
+		}
+		os.Rename(config.WatchEventsLog, config.WatchEventsLog+".1")
+	}
+
+	file, err := os.OpenFile(config.WatchEventsLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		warnf("⚠️ Warning: Could not open watch events log: %s\n", err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		warnf("⚠️ Warning: Could not write watch events log: %s\n", err)
+	}
+}
+
+// Run the on_file_change command once for each changed file, in parallel, and
+// wait for all of them. Files the commands rewrite, such as a formatter would,
+// are recorded as seen so that they do not trigger another build.
+func runOnFileChange(changes []fileChange, lastModified map[string]time.Time) {
+	var wg sync.WaitGroup
+	for _, change := range changes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			changeCmd := exec.Command("sh", "-c", config.OnFileChange)
+			changeCmd.Env = append(os.Environ(),
+				"PULSE_CHANGED_FILE="+change.path,
+				"PULSE_CHANGE_TYPE="+change.kind,
+				"PULSE_RELATIVE_PATH="+watchRelPath(change.path),
+			)
+			changeCmd.Stdout = os.Stdout
+			changeCmd.Stderr = os.Stderr
+			if err := changeCmd.Run(); err != nil {
+				warnf("⚠️ Warning: on_file_change command failed for %s: %s\n", change.path, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, change := range changes {
+		if _, ok := lastModified[change.path]; !ok {
+			continue
+		}
+		if info, err := os.Stat(change.path); err == nil {
+			lastModified[change.path] = info.ModTime()
+		}
+	}
+}
+
+// processExit describes a managed process that has exited.
+type processExit struct {
+	cmd  *exec.Cmd
+	err  error
+	tail *tailWriter
+}
+
+// Report a process that exited without being stopped by pulse.
+func handleProcessExit(exit processExit) {
+	cmd = nil
+
+	exitCode := exit.cmd.ProcessState.ExitCode()
+	if exitCode == 0 {
+		logln("🏁 Program exited")
+		return
+	}
+
+	errorf("💥 Program exited with code %d\n", exitCode)
+	// Only processes started with process_crash_report on have their output
+	// captured, so turning it on by a reload applies from the next start
+	if exit.tail != nil {
+		writeCrashReport(exit, exitCode)
+	}
+}
+
+// Write a JSON crash report to the artifact directory and run the on_crash
+// command, if any, with the report path in PULSE_CRASH_REPORT.
+func writeCrashReport(exit processExit, exitCode int) {
+	now := time.Now()
+	report := struct {
+		ExitCode int       `json:"exit_code"`
+		PID      int       `json:"pid"`
+		Time     time.Time `json:"time"`
+		Output   []string  `json:"output"`
+	}{
+		ExitCode: exitCode,
+		PID:      exit.cmd.ProcessState.Pid(),
+		Time:     now,
+		Output:   exit.tail.Lines(),
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		warnf("⚠️ Warning: Could not create crash report: %s\n", err)
+		return
+	}
+	if err := os.MkdirAll(config.ArtifactDir, 0755); err != nil {
+		warnf("⚠️ Warning: Could not create artifact dir: %s\n", err)
+		return
+	}
+	path := filepath.Join(config.ArtifactDir, fmt.Sprintf("crash_%s_%d.json", now.Format("20060102_150405.000"), report.PID))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		warnf("⚠️ Warning: Could not write crash report: %s\n", err)
+		return
+	}
+	logf("📄 Crash report written to %s\n", path)
+
+	if config.OnCrash != "" {
+		crashCmd := exec.Command("sh", "-c", config.OnCrash)
+		crashCmd.Env = append(os.Environ(), "PULSE_CRASH_REPORT="+path)
+		crashCmd.Stdout = os.Stdout
+		crashCmd.Stderr = os.Stderr
+		if err := crashCmd.Run(); err != nil {
+			warnf("⚠️ Warning: on_crash command failed: %s\n", err)
+		}
+	}
+}
+
+// tailWriter keeps the last max lines written to it.
+type tailWriter struct {
+	mu      sync.Mutex
+	max     int
+	lines   []string
+	partial string
+}
+
+func (t *tailWriter) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	parts := strings.Split(t.partial+string(p), "\n")
+	t.partial = parts[len(parts)-1]
+	t.lines = append(t.lines, parts[:len(parts)-1]...)
+	if len(t.lines) > t.max {
+		t.lines = t.lines[len(t.lines)-t.max:]
+	}
+	return len(p), nil
+}
+
+// Lines returns the captured lines, including any unterminated last line.
+func (t *tailWriter) Lines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lines := append([]string{}, t.lines...)
+	if t.partial != "" {
+		lines = append(lines, t.partial)
+	}
+	return lines
+}
+
+// Copy the copy_config_files into the binary's directory, keeping their
+// paths relative to it, so that programs looking for files next to their
+// executable find them. Failures are reported as warnings.
+func copyConfigFiles() {
+	dir := filepath.Dir(config.BinaryName)
+	for _, path := range config.CopyConfigFiles {
+		dest := filepath.Join(dir, path)
+		var err error
+		if config.UseCopySymlinks {
+			err = linkConfigFile(path, dest)
+		} else {
+			err = copyConfigFile(path, dest)
+		}
+		if err != nil {
+			warnf("⚠️ Warning: Could not copy %s: %s\n", path, err)
+		}
+	}
+}
+
+// Link dest to src, replacing whatever dest was.
+func linkConfigFile(src, dest string) error {
+	target, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	if current, err := os.Readlink(dest); err == nil && current == target {
+		return nil
+	}
+	if err := os.RemoveAll(dest); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	return os.Symlink(target, dest)
+}
+
+// Copy a file or directory tree from src to dest.
+func copyConfigFile(src, dest string) error {
+	// A link left by use_copy_symlinks would make the copy overwrite src
+	if info, err := os.Lstat(dest); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(dest); err != nil {
+			return err
+		}
+	}
+
+	return filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		// Unchanged files are left alone so watched copies do not look modified
+		if current, err := os.ReadFile(target); err == nil && bytes.Equal(current, data) {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, info.Mode().Perm())
+	})
+}
+
+// Reports whether a file is TypeScript source handled by the TypeScript
+// check or build.
+func isTypeScriptFile(path string) bool {
+	if !config.TypeScriptCheck && !config.TypeScriptBuild {
+		return false
+	}
+	return strings.HasSuffix(path, ".ts") || strings.HasSuffix(path, ".tsx")
+}
+
+// Type check the TypeScript project in the background. Errors are reported
+// but do not affect the Go build. Checks do not overlap, a change during a
+// check is covered by the next one.
+func checkTypeScript(tsconfig string) {
+	if !tsChecking.CompareAndSwap(false, true) {
+		return
+	}
+	defer tsChecking.Store(false)
+
+	logln("🔎 Type checking TypeScript...")
+	checkCmd := exec.Command("npx", "tsc", "--noEmit", "--project", tsconfig)
+	checkCmd.Stdout = os.Stdout
+	checkCmd.Stderr = os.Stderr
+	if err := checkCmd.Run(); err != nil {
+		warnf("⚠️ TypeScript check failed: %s\n", err)
+		return
+	}
+	successf("✅ TypeScript check passed\n")
+}
+
+// Compile the TypeScript project, reporting whether it succeeded.
+func buildTypeScript() bool {
+	tscCmd := exec.Command("npx", "tsc", "--project", config.TSConfigPath)
+	tscCmd.Stdout = os.Stdout
+	tscCmd.Stderr = os.Stderr
+	if err := tscCmd.Run(); err != nil {
+		errorf("❌ TypeScript build failed: %s\n", err)
+		return false
+	}
+	return true
+}
+
+// Reports whether a file is a migration. migration_dir is either a directory
+// or a glob pattern such as "migrations/*.sql".
+func isMigrationFile(path string) bool {
+	if !config.WatchMigrations {
+		return false
+	}
+	if ok, _ := filepath.Match(config.MigrationDir, path); ok {
+		return true
+	}
+	rel, err := filepath.Rel(config.MigrationDir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Hash the names and contents of all migration files.
+func migrationHash() (string, error) {
+	files, err := filepath.Glob(config.MigrationDir)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(config.MigrationDir); err == nil && info.IsDir() {
+		files = nil
+		err := filepath.Walk(config.MigrationDir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				files = append(files, path)
+			}
+			return err
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	sort.Strings(files)
+
+	hash := sha256.New()
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hash, "%s\n%d\n", file, len(data))
+		hash.Write(data)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Run the migration command if the migration files changed since the last
+// successful run. Reports whether the build can go ahead.
+func runMigrations() bool {
+	hash, err := migrationHash()
+	if err != nil {
+		errorf("❌ Could not read migrations: %s\n", err)
+		return false
+	}
+	if lock, err := os.ReadFile(migrationLockFile); err == nil && strings.TrimSpace(string(lock)) == hash {
+		return true
+	}
+
+	logln("🗃️ Running migrations...")
+	migrateCmd := exec.Command("sh", "-c", config.MigrationCommand)
+	migrateCmd.Env = commandEnv()
+	migrateCmd.Stdout = os.Stdout
+	migrateCmd.Stderr = os.Stderr
+	if err := migrateCmd.Run(); err != nil {
+		errorf("❌ Migrations failed: %s\n", err)
+		return false
+	}
+
+	if err := os.WriteFile(migrationLockFile, []byte(hash+"\n"), 0644); err != nil {
+		warnf("⚠️ Warning: Could not write %s: %s\n", migrationLockFile, err)
+	}
+	successf("✅ Migrations applied\n")
+	return true
+}
+
+// Run govulncheck and report its findings. Checks run at most once per
+// vulnCheckInterval, reusing the previous result in between. Reports whether
+// the build can go ahead, which is only prevented by findings when
+// vuln_check_strict is set.
+func runVulnCheck() bool {
+	if time.Since(vulnCheck.last) < vulnCheckInterval {
+		return vulnCheck.passed || !config.VulnCheckStrict
+	}
+	vulnCheck.last = time.Now()
+	vulnCheck.passed = true
+	if !ensureTool("govulncheck") {
+		return true
+	}
+
+	logln("🔎 Checking for vulnerabilities...")
+	vulnCmd := exec.Command("govulncheck", "./...")
+	output, err := vulnCmd.CombinedOutput()
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return true
+	// govulncheck exits with 3 when it finds vulnerabilities
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 3:
+		vulnCheck.passed = false
+		for _, line := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+			warnf("⚠️ VULN: %s\n", line)
+		}
+		if config.VulnCheckStrict {
+			errorf("❌ Vulnerabilities found\n")
+			return false
+		}
+		return true
+	default:
+		warnf("⚠️ Warning: govulncheck failed: %s\n", err)
+		os.Stderr.Write(output)
+		return true
+	}
+}
+
+// Run go mod tidy every interval until the context is done. Changes it makes
+// to go.mod and go.sum are picked up by the watcher. Runs are skipped while a
+// build is in progress.
+func tidyPeriodically(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if building.Load() {
+				continue
+			}
+			if output, err := exec.CommandContext(ctx, "go", "mod", "tidy").CombinedOutput(); err != nil && ctx.Err() == nil {
+				warnf("⚠️ Warning: go mod tidy failed: %s\n", err)
+				os.Stderr.Write(output)
+			}
+		}
+	}
+}
+
+// Create the command that builds the program, build_command when it is set
+// and go build otherwise.
+func buildCommand(ctx context.Context) *exec.Cmd {
+	if len(config.BuildCommand) > 0 {
+		args := buildCommandArgs()
+		buildCmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		buildCmd.Stdout = os.Stdout
+		return buildCmd
+	}
+	return exec.CommandContext(ctx, "go", buildArgs()...)
+}
+
+// Build the arguments passed to the go command to build the program.
+func buildArgs() []string {
+	args := append([]string{"build"}, buildFlags()...)
+	return append(append(args, "-o", buildOutputPath()), mainFiles()...)
+}
+
+// Build the build_command arguments, replacing {{main_file}} with main_file.
+func buildCommandArgs() []string {
+	args := make([]string, len(config.BuildCommand))
+	for i, arg := range config.BuildCommand {
+		args[i] = strings.ReplaceAll(arg, "{{main_file}}", config.MainFile)
+	}
+	return args
+}
+
+// Returns the path go build writes the binary to. With atomic_binary_replace
+// the binary is built next to the current one and renamed over it afterwards,
+// so the binary at BinaryName is always complete.
+func buildOutputPath() string {
+	if config.AtomicBinaryReplace {
+		return config.BinaryName + ".new"
+	}
+	return config.BinaryName
+}
+
+// Returns what go build and go run are given to build the program:
+// main_package when set, main_file otherwise.
+func mainTarget() string {
+	if config.MainPackage != "" {
+		return config.MainPackage
+	}
+	return config.MainFile
+}
+
+// Returns the directory of the main package.
+func mainPackageDir() string {
+	if config.MainPackage != "" {
+		return filepath.Clean(config.MainPackage)
+	}
+	return filepath.Dir(config.MainFile)
+}
+
+// Returns the files to build the program from. Files listed on the command
+// line are built on their own, so the injected pprof file has to be added.
+func mainFiles() []string {
+	files := []string{mainTarget()}
+	if pprofOverlay != "" && strings.HasSuffix(files[0], ".go") {
+		files = append(files, filepath.Join(mainPackageDir(), pprofFile))
+	}
+	return files
+}
+
+// Write a go build overlay that adds a file starting a pprof server to the
+// main package, and return the path of the overlay.
+func writePprofOverlay() (string, error) {
+	mainDir, err := filepath.Abs(mainPackageDir())
+	if err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp("", "pulse-pprof-")
+	if err != nil {
+		return "", err
+	}
+
+	source := fmt.Sprintf(`package main
+
+import (
+	"log"
+	"net/http"
+	_ "net/http/pprof"
+)
+
+func init() {
+	go func() {
+		log.Println(http.ListenAndServe("localhost:%d", nil))
+	}()
+}
+`, config.PProfPort)
+	sourcePath := filepath.Join(dir, pprofFile)
+	if err := os.WriteFile(sourcePath, []byte(source), 0644); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	overlay, err := json.Marshal(map[string]map[string]string{
+		"Replace": {filepath.Join(mainDir, pprofFile): sourcePath},
+	})
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	overlayPath := filepath.Join(dir, "overlay.json")
+	if err := os.WriteFile(overlayPath, overlay, 0644); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return overlayPath, nil
+}
+
+// Build the environment for go build. Returns nil, meaning inherit the
+// environment of pulse, when nothing needs to be added.
+func buildEnv() []string {
+	extra := []string{}
+	if config.BuildMemoryLimit != "" {
+		extra = append(extra, "GOMEMLIMIT="+config.BuildMemoryLimit)
+	}
+
+	// Later entries win, so these override any CGO flags pulse inherited
+	keys := make([]string, 0, len(config.CGOFlags))
+	for key := range config.CGOFlags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		extra = append(extra, key+"="+config.CGOFlags[key])
+	}
+
+	if len(extra) == 0 {
+		return nil
+	}
+	return append(os.Environ(), extra...)
+}
+
+// Reports whether a value uses the GOMEMLIMIT format: a number of bytes with
+// an optional B, KiB, MiB, GiB, or TiB suffix, or "off".
+func validMemoryLimit(limit string) bool {
+	if limit == "off" {
+		return true
+	}
+	for _, suffix := range []string{"TiB", "GiB", "MiB", "KiB", "B"} {
+		if trimmed, ok := strings.CutSuffix(limit, suffix); ok {
+			limit = trimmed
+			break
+		}
+	}
+	n, err := strconv.ParseUint(limit, 10, 64)
+	return err == nil && n > 0
+}
+
+// Build the flags shared by go build and go run.
+func buildFlags() []string {
+	flags := append([]string{}, config.BuildFlags...)
+	if config.RaceDetector {
+		flags = append(flags, "-race")
+	}
+	if config.StripDebugSymbols {
+		flags = prependLDFlags(flags, "-s -w")
+	}
+	if config.BuildID != "" {
+		flags = prependLDFlags(flags, "-buildid="+expandBuildID(config.BuildID))
+	}
+	if config.TrimPath {
+		flags = append(flags, "-trimpath")
+	}
+	tags := append(slices.Clone(config.BuildTags), readBuildTagsFile()...)
+	tags = append(append(tags, packageTags(mainPackageDir())...), autoBuildTags...)
+	if len(tags) > 0 {
+		flags = append(flags, "-tags="+strings.Join(tags, ","))
+	}
+	if pprofOverlay != "" {
+		flags = append(flags, "-overlay="+pprofOverlay)
+	}
+	return flags
+}
 
-			if err != nil {
-				errCh <- err
-				return
+// Prepend ldflags to the -ldflags in flags, adding the flag if it is not there.
+// Only the last -ldflags counts, so an existing value has to be extended.
+func prependLDFlags(flags []string, ldflags string) []string {
+	for i := len(flags) - 1; i >= 0; i-- {
+		name, value, ok := strings.Cut(strings.TrimPrefix(flags[i], "-"), "=")
+		if name != "-ldflags" && name != "ldflags" {
+			continue
+		}
+		if ok {
+			flags[i] = "-ldflags=" + ldflags + " " + value
+		} else if i+1 < len(flags) {
+			flags[i+1] = ldflags + " " + flags[i+1]
+		}
+		return flags
+	}
+	return append(flags, "-ldflags="+ldflags)
+}
+
+// Replace {commit}, {time} and {branch} in a build_id with the git commit, the
+// Unix time and the git branch.
+func expandBuildID(id string) string {
+	if strings.Contains(id, "{branch}") {
+		branch := "unknown"
+		if out, err := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD").Output(); err == nil {
+			branch = strings.TrimSpace(string(out))
+		}
+		id = strings.ReplaceAll(id, "{branch}", branch)
+	}
+	if strings.Contains(id, "{commit}") {
+		id = strings.ReplaceAll(id, "{commit}", gitCommit())
+	}
+	return strings.ReplaceAll(id, "{time}", strconv.FormatInt(time.Now().Unix(), 10))
+}
+
+// Return the tags for build_tags_auto_detect: the operating system, the
+// architecture and the Go release of the toolchain, such as go1.22. The go
+// command satisfies these on its own, listing them makes them explicit.
+func detectBuildTags() []string {
+	tags := []string{runtime.GOOS, runtime.GOARCH}
+	out, err := exec.Command("go", "env", "GOVERSION").Output()
+	if err != nil {
+		return tags
+	}
+	if version, ok := parseGoVersion(strings.TrimSpace(string(out))); ok {
+		tags = append(tags, fmt.Sprintf("go%d.%d", version[0], version[1]))
+	}
+	return tags
+}
+
+// Drop the tags that cannot be passed to -tags, which joins them with commas.
+func validBuildTags(tags []string) []string {
+	valid := []string{}
+	for _, tag := range tags {
+		if tag == "" || strings.ContainsAny(tag, ", \t") {
+			warnf("⚠️ Warning: Invalid build tag %q, tags cannot be empty or contain spaces or commas\n", tag)
+			continue
+		}
+		valid = append(valid, tag)
+	}
+	return valid
+}
+
+// Read the tags in watch_build_tags_file, one per line. Blank lines and #
+// comments are skipped. The file is read for every build so that other tools
+// can change the tags while pulse runs.
+func readBuildTagsFile() []string {
+	if config.BuildTagsFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(config.BuildTagsFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			warnf("⚠️ Warning: Could not read %s: %s\n", config.BuildTagsFile, err)
+		}
+		return nil
+	}
+
+	tags := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tags = append(tags, line)
+	}
+	return validBuildTags(tags)
+}
+
+// Read the build tags listed in the tag file of a package directory. The file
+// holds a whitespace separated list of tags.
+func packageTags(dir string) []string {
+	if config.TagFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(dir, config.TagFile))
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(data))
+}
+
+// Compile every watched package that has its own tag file with those tags.
+// The main package is skipped since it is built with its tags afterwards.
+func buildTaggedPackages() error {
+	mainDir := filepath.Clean(mainPackageDir())
+	dirs := []string{}
+
+	err := walkWatchDirs(func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && info.Name() == config.TagFile {
+			if dir := filepath.Dir(path); filepath.Clean(dir) != mainDir {
+				dirs = append(dirs, dir)
 			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-			if changes {
-				buildCh <- true
+	for _, dir := range dirs {
+		pkg := dir
+		if !filepath.IsAbs(pkg) {
+			pkg = "./" + filepath.ToSlash(pkg)
+		}
+		// A main package would otherwise leave its binary in the current
+		// directory
+		args := []string{"build", "-o", os.DevNull}
+		if tags := packageTags(dir); len(tags) > 0 {
+			args = append(args, "-tags="+strings.Join(tags, ","))
+		}
+		buildCmd := exec.Command("go", append(args, pkg)...)
+		buildCmd.Env = buildEnv()
+		buildCmd.Stderr = os.Stderr
+		if err := buildCmd.Run(); err != nil {
+			return fmt.Errorf("%s: %w", pkg, err)
+		}
+	}
+	return nil
+}
+
+// List the Go files under the watch roots that gofmt would reformat. gofmt
+// exits with an error when a file does not parse, but still lists the others.
+func unformattedFiles(roots []string) []string {
+	out, err := exec.Command("gofmt", append([]string{"-l"}, roots...)...).Output()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil
+	}
+	files := []string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		if line != "" {
+			files = append(files, line)
+		}
+	}
+	return files
+}
+
+// Build the arguments passed to the go command in test mode.
+func testArgs() []string {
+	args := []string{"test"}
+	if config.TestParallel > 0 {
+		args = append(args, "-parallel", strconv.Itoa(config.TestParallel))
+	}
+	if config.TestCount > 0 {
+		args = append(args, "-count", strconv.Itoa(config.TestCount))
+	}
+	if config.TestShort {
+		args = append(args, "-short")
+	}
+	if config.TestFailFast {
+		args = append(args, "-failfast")
+	}
+	if config.TestRace {
+		args = append(args, "-race")
+	}
+	return append(args, "./...")
+}
+
+// Run the tests, reporting whether they passed.
+func runTests() bool {
+	logln("🧪 Running tests...")
+
+	if config.UseGoRunForTests {
+		return runTestsWithGoRun()
+	}
+
+	testCmd := exec.Command("go", testArgs()...)
+	testCmd.Env = commandEnv()
+	testCmd.Stdout = os.Stdout
+	testCmd.Stderr = os.Stderr
+
+	if err := testCmd.Run(); err != nil {
+		errorf("❌ Tests failed: %s\n", err)
+		return false
+	}
+
+	successf("✅ Tests passed\n")
+	return true
+}
+
+// Run the program with go run for use_go_run_for_tests, taking the result from
+// a FAIL or PASS in its output.
+func runTestsWithGoRun() bool {
+	var output bytes.Buffer
+	args := append(append([]string{"run"}, buildFlags()...), mainFiles()...)
+	testCmd := exec.Command("go", args...)
+	testCmd.Env = commandEnv()
+	testCmd.Stdout = io.MultiWriter(os.Stdout, &output)
+	testCmd.Stderr = io.MultiWriter(os.Stderr, &output)
+
+	err := testCmd.Run()
+	switch {
+	case err != nil:
+		errorf("❌ Tests failed: %s\n", err)
+	case strings.Contains(output.String(), "FAIL"):
+		errorf("❌ Tests failed\n")
+	case strings.Contains(output.String(), "PASS"):
+		successf("✅ Tests passed\n")
+		return true
+	default:
+		warnf("⚠️ Program finished without printing PASS or FAIL\n")
+	}
+	return false
+}
+
+// Returns the modification time of a file, or the zero time if the path is
+// empty or the file cannot be read.
+func fileModTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// Parse the env file as simple KEY=VALUE lines. Blank lines and # comments are
+// skipped, and malformed lines are reported as warnings.
+func loadEnvFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			warnf("⚠️ Warning: Skipping invalid line %d in env file %s\n", i+1, path)
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// Build the environment for the managed process. Returns nil, meaning inherit
+// the environment of pulse, when there is nothing to add. Without
+// inherit_parent_env only the added variables are set.
+func processEnv() []string {
+	extra := []string{}
+
+	if config.EnvFile != "" {
+		values, err := loadEnvFile(config.EnvFile)
+		if err != nil {
+			warnf("⚠️ Warning: Could not read env file: %s\n", err)
+			values = envValues
+		}
+		if envValues != nil {
+			logEnvDiff(envValues, values)
+		}
+		envValues = values
+
+		for key, value := range values {
+			extra = append(extra, key+"="+value)
+		}
+	}
+
+	// Added after the env file so that inline values take precedence
+	for _, key := range slices.Sorted(maps.Keys(config.Env)) {
+		extra = append(extra, key+"="+os.ExpandEnv(config.Env[key]))
+	}
+
+	if config.InjectBuildTime {
+		extra = append(extra,
+			"BUILD_TIME="+lastBuildTime.Format(time.RFC3339),
+			"BUILD_COMMIT="+gitCommit(),
+		)
+	}
+
+	if config.InjectPulseVars {
+		changed, _ := lastChangedFile.Load().(string)
+		extra = append(extra,
+			"PULSE_VERSION="+Version,
+			"PULSE_BINARY="+config.BinaryName,
+			"PULSE_WATCH_DIR="+config.WatchDir,
+			"PULSE_BUILD_COUNT="+strconv.FormatInt(buildCount.Load(), 10),
+			"PULSE_LAST_CHANGED_FILE="+changed,
+			"PULSE_START_TIME="+time.Now().Format(time.RFC3339),
+		)
+	}
+
+	// go run needs PATH, HOME and GOCACHE itself, so it always inherits
+	if !config.InheritParentEnv && !config.RunAsModule {
+		return extra
+	}
+	if len(extra) == 0 {
+		return nil
+	}
+	return append(os.Environ(), extra...)
+}
+
+// Build the environment for the commands pulse runs besides the program, such
+// as go test. These always inherit the environment of pulse and only add the
+// env file and env values; unlike processEnv nothing is logged or recorded.
+func commandEnv() []string {
+	extra := []string{}
+	if config.EnvFile != "" {
+		values, err := loadEnvFile(config.EnvFile)
+		if err != nil {
+			values = envValues
+		}
+		for key, value := range values {
+			extra = append(extra, key+"="+value)
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(config.Env)) {
+		extra = append(extra, key+"="+os.ExpandEnv(config.Env[key]))
+	}
+	if len(extra) == 0 {
+		return nil
+	}
+	return append(os.Environ(), extra...)
+}
+
+// Returns the short SHA of the current git commit, or "unknown" outside of a
+// git repository. The result is cached until go.mod or go.sum change.
+func gitCommit() string {
+	key := ""
+	for _, file := range []string{"go.mod", "go.sum"} {
+		if info, err := os.Stat(file); err == nil {
+			key += info.ModTime().String() + ";"
+		}
+	}
+	if commitCache.commit != "" && commitCache.key == key {
+		return commitCache.commit
+	}
+
+	commit := "unknown"
+	if out, err := exec.Command("git", "rev-parse", "--short", "HEAD").Output(); err == nil {
+		commit = strings.TrimSpace(string(out))
+	}
+	commitCache.commit = commit
+	commitCache.key = key
+	return commit
+}
+
+// Print which variables were added, changed, or removed between two loads of
+// the env file.
+func logEnvDiff(old, new map[string]string) {
+	keys := make([]string, 0, len(old)+len(new))
+	for key := range old {
+		keys = append(keys, key)
+	}
+	for key := range new {
+		if _, ok := old[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		oldValue, hadOld := old[key]
+		newValue, hasNew := new[key]
+		switch {
+		case !hadOld:
+			logf("   + %s=%s\n", key, maskEnvValue(key, newValue))
+		case !hasNew:
+			logf("   - %s\n", key)
+		case oldValue != newValue:
+			logf("   ~ %s=%s\n", key, maskEnvValue(key, newValue))
+		}
+	}
+}
+
+// Hide the values of variables that look like secrets.
+func maskEnvValue(key, value string) string {
+	upper := strings.ToUpper(key)
+	for _, word := range []string{"PASSWORD", "SECRET", "TOKEN", "KEY"} {
+		if strings.Contains(upper, word) {
+			return "****"
+		}
+	}
+	return value
+}
+
+// activityWriter records the time of the last write that passed through it.
+type activityWriter struct {
+	w         io.Writer
+	lastWrite atomic.Int64
+}
+
+func (a *activityWriter) Write(p []byte) (int, error) {
+	a.lastWrite.Store(time.Now().UnixNano())
+	return a.w.Write(p)
+}
+
+// Signal a process that has stopped writing output. After the I/O timeout it
+// gets SIGQUIT, which makes Go programs dump their goroutines, and after twice
+// the timeout it gets SIGTERM.
+func watchActivity(proc *exec.Cmd, done <-chan struct{}, activity *activityWriter, timeout time.Duration) {
+	ticker := time.NewTicker(max(timeout/4, 10*time.Millisecond))
+	defer ticker.Stop()
+
+	quitSent := false
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			idle := time.Since(time.Unix(0, activity.lastWrite.Load()))
+			switch {
+			case idle < timeout:
+				quitSent = false
+			case !quitSent:
+				warnf("⚠️ Warning: No output for %s, sending SIGQUIT\n", idle.Round(time.Millisecond))
+				quitProcess(proc)
+				quitSent = true
+			case idle >= 2*timeout:
+				warnf("⚠️ Warning: No output for %s, sending SIGTERM\n", idle.Round(time.Millisecond))
+				proc.Process.Signal(syscall.SIGTERM)
+				return
 			}
 		}
 	}
 }
 
-func shouldWatch(filename string) bool {
-	for _, ext := range config.WatchExts {
-		if strings.HasSuffix(filename, ext) {
+// outputLimit is the output budget shared by the stdout and stderr of one
+// process.
+type outputLimit struct {
+	mu      sync.Mutex
+	max     int64
+	written int64
+}
+
+// limitedWriter passes writes through until the shared limit is reached and
+// discards them afterwards.
+type limitedWriter struct {
+	w     io.Writer
+	limit *outputLimit
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	l.limit.mu.Lock()
+	defer l.limit.mu.Unlock()
+
+	remaining := l.limit.max - l.limit.written
+	if remaining <= 0 {
+		return len(p), nil
+	}
+	if int64(len(p)) <= remaining {
+		l.limit.written += int64(len(p))
+		return l.w.Write(p)
+	}
+
+	l.limit.written = l.limit.max
+	if _, err := l.w.Write(p[:remaining]); err != nil {
+		return 0, err
+	}
+	logf("\n[output truncated at %d bytes]\n", l.limit.max)
+	return len(p), nil
+}
+
+// markerWriter clears a watch_interval_display marker on the current line
+// before passing writes through, so that program output starts on a clean
+// line.
+type markerWriter struct {
+	w io.Writer
+}
+
+func (m markerWriter) Write(p []byte) (int, error) {
+	if tickShown.Swap(false) {
+		io.WriteString(m.w, "\r\033[K")
+	}
+	return m.w.Write(p)
+}
+
+// quietWriter discards everything written to it until a deadline has passed,
+// after which writes pass through to the wrapped writer.
+type quietWriter struct {
+	w     io.Writer
+	until time.Time
+}
+
+func (q *quietWriter) Write(p []byte) (int, error) {
+	if time.Now().Before(q.until) {
+		return len(p), nil
+	}
+	return q.w.Write(p)
+}
+
+// Compare the installed Go version with min_go_version, printing a warning or
+// error if it is older. Reports whether the version is acceptable.
+func checkGoVersion() bool {
+	want, ok := parseGoVersion(config.MinGoVersion)
+	if !ok {
+		warnf("⚠️ Warning: Invalid min_go_version %q\n", config.MinGoVersion)
+		return true
+	}
+
+	out, err := exec.Command("go", "version").Output()
+	if err != nil {
+		warnf("⚠️ Warning: Could not determine the Go version: %s\n", err)
+		return true
+	}
+
+	// The output looks like "go version go1.21.3 linux/amd64". Development
+	// builds report "go version devel ..." and are assumed to be new enough.
+	fields := strings.Fields(string(out))
+	if len(fields) < 3 || fields[2] == "devel" {
+		return true
+	}
+	have, ok := parseGoVersion(fields[2])
+	if !ok {
+		return true
+	}
+
+	for i := range want {
+		if have[i] > want[i] {
 			return true
 		}
+		if have[i] < want[i] {
+			if config.GoVersionStrict {
+				errorf("❌ Go %s is required, but %s is installed\n", config.MinGoVersion, fields[2])
+			} else {
+				warnf("⚠️ Warning: Go %s is required, but %s is installed\n", config.MinGoVersion, fields[2])
+			}
+			return false
+		}
 	}
-	return false
+	return true
 }
 
-func buildAndRun() {
-	fmt.Println("🔨 Building...")
+// Parse a Go version such as "1.21", "go1.21.3", or "go1.21rc2" into its
+// major, minor, and patch numbers. Pre-release suffixes are ignored.
+func parseGoVersion(version string) ([3]int, bool) {
+	var parts [3]int
 
-	// Build the program
-	buildCmd := exec.Command("go", "build", "-o", config.BinaryName, config.MainFile)
-	buildCmd.Stderr = os.Stderr
+	version = strings.TrimPrefix(version, "go")
+	if end := strings.IndexFunc(version, func(r rune) bool {
+		return r != '.' && (r < '0' || r > '9')
+	}); end >= 0 {
+		version = version[:end]
+	}
 
-	if err := buildCmd.Run(); err != nil {
-		fmt.Printf("❌ Build failed: %s\n", err)
+	fields := strings.Split(version, ".")
+	if len(fields) > 3 {
+		return parts, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+// Install the missing tools of enabled features at startup when
+// auto_install_tools is set. A feature whose tool cannot be installed is
+// disabled.
+func installTools() {
+	if !config.AutoInstallTools {
 		return
 	}
+	if config.RunVulnCheck && !ensureTool("govulncheck") {
+		warnf("⚠️ Warning: Disabling dependency_vulnerability_check\n")
+		config.RunVulnCheck = false
+	}
+}
 
-	fmt.Println("✅ Build successful")
-	fmt.Println("🚀 Running program...")
+// Make sure a tool is available in PATH, installing it with go install when
+// auto_install_tools is enabled. Reports whether the tool can be used.
+func ensureTool(name string) bool {
+	if _, err := exec.LookPath(name); err == nil {
+		return true
+	}
 
-	// Run the compiled program
-	cmd = exec.Command("./" + config.BinaryName)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	module, known := toolModules[name]
+	if !config.AutoInstallTools || !known {
+		warnf("⚠️ Warning: %s is not installed\n", name)
+		return false
+	}
 
-	if err := cmd.Start(); err != nil {
-		fmt.Printf("❌ Error starting program: %s\n", err)
-		return
+	logf("📦 Installing %s...\n", name)
+	installCmd := exec.Command("go", "install", module)
+	installCmd.Stdout = os.Stdout
+	installCmd.Stderr = os.Stderr
+	if err := installCmd.Run(); err != nil {
+		warnf("⚠️ Warning: Could not install %s: %s\n", name, err)
+		return false
+	}
+
+	// go install puts binaries in GOBIN, which may not be in PATH
+	if _, err := exec.LookPath(name); err != nil {
+		warnf("⚠️ Warning: %s was installed but is not in PATH\n", name)
+		return false
 	}
+	successf("✅ Installed %s\n", name)
+	return true
+}
 
-	fmt.Println("✅ Program is running...")
+// Send the hot reload signal to the running program.
+func sendReloadSignal() {
+	if cmd == nil || cmd.Process == nil {
+		return
+	}
+	if err := cmd.Process.Signal(hotReloadSignal); err != nil {
+		warnf("⚠️ Warning: Could not send %s: %s\n", config.HotReloadSignal, err)
+		return
+	}
+	logf("📡 Sent %s to program\n", config.HotReloadSignal)
 }
 
+// Stop the running process. It is sent SIGTERM first and killed if it has
+// not exited after stop_grace_period.
 func stopProcess() {
 	if cmd != nil && cmd.Process != nil {
-		fmt.Println("🛑 Stopping previous process...")
-		cmd.Process.Kill()
-		cmd.Wait()
+		logln("🛑 Stopping previous process...")
+		kill := func() {
+			if config.RunAsModule {
+				// Also kill the program started by go run
+				killProcessGroup(cmd)
+			} else {
+				cmd.Process.Kill()
+			}
+		}
+
+		grace, _ := time.ParseDuration(config.StopGracePeriod)
+		if grace == 0 || terminateProcess(cmd, config.RunAsModule) != nil {
+			kill()
+			<-cmdDone
+		} else {
+			select {
+			case <-cmdDone:
+			case <-time.After(grace):
+				logf("⏳ Process did not exit within %s, killing it\n", grace)
+				kill()
+				<-cmdDone
+			}
+		}
+		lastStopTime = time.Now()
+	}
+	cmd = nil
+}
+
+// Level of a line of pulse's own output
+type logLevel int
+
+const (
+	levelInfo logLevel = iota
+	levelSuccess
+	levelWarning
+	levelError
+)
+
+// ANSI colors for the levels: red for errors, yellow for warnings and green
+// for success. Info keeps the default terminal color.
+var levelColors = map[logLevel]string{
+	levelSuccess: "\033[32m",
+	levelWarning: "\033[33m",
+	levelError:   "\033[31m",
+}
+
+// logger writes pulse's own output, colored by level when colorize is set.
+// colorize is atomic since a config reload sets it while other goroutines log.
+type logger struct {
+	w        io.Writer
+	colorize atomic.Bool
+}
+
+// Write a message at a level. A watch_interval_display marker on the current
+// line is cleared first.
+func (l *logger) logf(level logLevel, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if color := levelColors[level]; l.colorize.Load() && color != "" {
+		line := strings.TrimSuffix(msg, "\n")
+		msg = color + line + "\033[0m" + msg[len(line):]
 	}
+	if tickShown.Swap(false) {
+		msg = "\r\033[K" + msg
+	}
+	io.WriteString(l.w, msg)
+}
+
+// The logger for everything pulse prints
+var output = &logger{w: os.Stdout}
+
+// Print a line of pulse's own output at the info level.
+func logf(format string, args ...any) {
+	output.logf(levelInfo, format, args...)
+}
+
+// Like logf, formatting its arguments as fmt.Println does.
+func logln(args ...any) {
+	logf("%s", fmt.Sprintln(args...))
+}
+
+// Print a success message, green when color_output_by_level is enabled.
+func successf(format string, args ...any) {
+	output.logf(levelSuccess, format, args...)
+}
+
+// Print a warning, yellow when color_output_by_level is enabled.
+func warnf(format string, args ...any) {
+	output.logf(levelWarning, format, args...)
+}
+
+// Print an error, red when color_output_by_level is enabled.
+func errorf(format string, args ...any) {
+	output.logf(levelError, format, args...)
+}
+
+// Reports whether pulse's own output should be colored with the config.
+// NO_COLOR and output that is not a terminal turn colors off.
+func colorEnabled() bool {
+	return config.ColorOutputByLevel && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout)
+}
+
+// Reports whether a file is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
 }
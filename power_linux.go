@@ -0,0 +1,20 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Returns the number of times the system has suspended since boot, or zero if
+// the kernel does not report it.
+func suspendCount() int {
+	data, err := os.ReadFile("/sys/power/suspend_stats/success")
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return n
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+// Suspends are not counted on this platform, resumes are only detected by
+// the gap between the wall and monotonic clocks.
+func suspendCount() int {
+	return 0
+}
@@ -0,0 +1,13 @@
+//go:build !unix || aix
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Setting the nice level is not supported on this platform.
+func setNiceLevel(pid, level int) error {
+	return fmt.Errorf("process_nice_level is not supported on %s", runtime.GOOS)
+}
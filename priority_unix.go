@@ -0,0 +1,13 @@
+//go:build unix && !aix
+
+package main
+
+import "syscall"
+
+// Replaced in tests
+var setpriority = syscall.Setpriority
+
+// Set the nice level of a process. Lowering it below 0 needs privileges.
+func setNiceLevel(pid, level int) error {
+	return setpriority(syscall.PRIO_PROCESS, pid, level)
+}
@@ -0,0 +1,30 @@
+//go:build unix && !aix
+
+package main
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestSetNiceLevel(t *testing.T) {
+	type call struct{ which, who, prio int }
+	var calls []call
+	saved := setpriority
+	setpriority = func(which, who, prio int) error {
+		calls = append(calls, call{which, who, prio})
+		return nil
+	}
+	t.Cleanup(func() { setpriority = saved })
+
+	for _, level := range []int{-20, 0, 10, 19} {
+		calls = nil
+		if err := setNiceLevel(1234, level); err != nil {
+			t.Fatalf("setNiceLevel(1234, %d): %v", level, err)
+		}
+		want := call{syscall.PRIO_PROCESS, 1234, level}
+		if len(calls) != 1 || calls[0] != want {
+			t.Errorf("setNiceLevel(1234, %d) made calls %v, want [%v]", level, calls, want)
+		}
+	}
+}
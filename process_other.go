@@ -0,0 +1,37 @@
+//go:build !unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// Process groups are not supported on this platform.
+func setProcessGroup(c *exec.Cmd) {}
+
+// Kill the process. Children it started are not killed on this platform.
+func killProcessGroup(c *exec.Cmd) error {
+	return c.Process.Kill()
+}
+
+// SIGTERM is not supported on this platform, so processes are always killed.
+func terminateProcess(c *exec.Cmd, group bool) error {
+	return fmt.Errorf("SIGTERM is not supported on this platform")
+}
+
+// SIGQUIT is not supported on this platform.
+func quitProcess(c *exec.Cmd) error {
+	return fmt.Errorf("SIGQUIT is not supported on this platform")
+}
+
+// There is no umask on this platform, so the process is started as is.
+func setUmask(c *exec.Cmd, mask int) error {
+	return nil
+}
+
+// Sending arbitrary signals is not supported on this platform.
+func parseSignal(name string) (os.Signal, error) {
+	return nil, fmt.Errorf("signals are not supported on this platform")
+}
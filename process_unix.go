@@ -0,0 +1,72 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// Start the process in its own process group so that it can be killed along
+// with any children it starts.
+func setProcessGroup(c *exec.Cmd) {
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// Kill every process in the process group of c.
+func killProcessGroup(c *exec.Cmd) error {
+	return syscall.Kill(-c.Process.Pid, syscall.SIGKILL)
+}
+
+// Ask the process to exit with SIGTERM, sending it to the whole process group
+// when group is set.
+func terminateProcess(c *exec.Cmd, group bool) error {
+	if group {
+		return syscall.Kill(-c.Process.Pid, syscall.SIGTERM)
+	}
+	return c.Process.Signal(syscall.SIGTERM)
+}
+
+// Send SIGQUIT to the process, which makes Go programs dump their goroutines.
+func quitProcess(c *exec.Cmd) error {
+	return c.Process.Signal(syscall.SIGQUIT)
+}
+
+// Make the process start with the given umask. Go cannot run code in the
+// child between fork and exec, so the command is run through sh, which sets
+// the umask and then replaces itself with the command.
+func setUmask(c *exec.Cmd, mask int) error {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		return err
+	}
+	script := fmt.Sprintf(`umask %04o && exec "$@"`, mask)
+	c.Args = append([]string{"sh", "-c", script, "sh", c.Path}, c.Args[1:]...)
+	c.Path = sh
+	return nil
+}
+
+var signalNames = map[string]syscall.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+}
+
+// Parse a signal name such as "SIGHUP" or "HUP".
+func parseSignal(name string) (os.Signal, error) {
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	sig, ok := signalNames[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported signal %q", name)
+	}
+	return sig, nil
+}
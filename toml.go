@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Decode a TOML document into a map of its keys. Dates and times are written
+// back as their TOML text, since no option takes a time.
+func decodeTOML(data []byte) (map[string]any, error) {
+	values := make(map[string]any)
+	if _, err := toml.Decode(string(data), &values); err != nil {
+		return nil, err
+	}
+	tomlDates(values)
+	return values, nil
+}
+
+// Replace the times in maps and arrays with their text. Local dates and times
+// are decoded in zones named after their TOML type.
+func tomlDates(v any) any {
+	switch v := v.(type) {
+	case time.Time:
+		switch v.Location().String() {
+		case "date-local":
+			return v.Format(time.DateOnly)
+		case "time-local":
+			return v.Format("15:04:05.999999999")
+		case "datetime-local":
+			return v.Format("2006-01-02T15:04:05.999999999")
+		}
+		return v.Format(time.RFC3339Nano)
+	case map[string]any:
+		for key, item := range v {
+			v[key] = tomlDates(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = tomlDates(item)
+		}
+	case []map[string]any:
+		for _, item := range v {
+			tomlDates(item)
+		}
+	}
+	return v
+}
+
+// Encode a struct as TOML, one key per field named by its json tag. Keys are
+// sorted, with maps written as tables after the other keys.
+func encodeTOML(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var values map[string]any
+	if err := decoder.Decode(&values); err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := toml.NewEncoder(&b).Encode(tomlNumbers(values)); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Convert the numbers of decoded JSON to integers where they are whole, and
+// drop null values, which TOML has no way to write.
+func tomlNumbers(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for key, item := range v {
+			if item == nil {
+				delete(v, key)
+			} else {
+				v[key] = tomlNumbers(item)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = tomlNumbers(item)
+		}
+	}
+	return v
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeTOML(t *testing.T) {
+	tests := []struct {
+		name string
+		toml string
+		want map[string]any
+	}{
+		{"empty", "", map[string]any{}},
+		{"scalars", "binary_name = \"app\"\nmax_restarts = 3\nwatch_only = true\n", map[string]any{
+			"binary_name":  "app",
+			"max_restarts": int64(3),
+			"watch_only":   true,
+		}},
+		{"table", "[env]\nPORT = 8080\nNAME = 'app'\n", map[string]any{
+			"env": map[string]any{"PORT": int64(8080), "NAME": "app"},
+		}},
+		{"inline table", "env = { PORT = \"8080\" }\n", map[string]any{
+			"env": map[string]any{"PORT": "8080"},
+		}},
+		{"dotted keys", "env.PORT = \"8080\"\n", map[string]any{
+			"env": map[string]any{"PORT": "8080"},
+		}},
+		{"multi-line strings", "migration_command = \"\"\"\nmigrate up\nseed\"\"\"\nbuild_id = '''\nraw\\n'''\n", map[string]any{
+			"migration_command": "migrate up\nseed",
+			"build_id":          "raw\\n",
+		}},
+		{"dates", "released = 2024-01-02\nat = 2024-01-02T15:04:05Z\nlocal = 2024-01-02T15:04:05\nopens = 09:30:00\n", map[string]any{
+			"released": "2024-01-02",
+			"at":       "2024-01-02T15:04:05Z",
+			"local":    "2024-01-02T15:04:05",
+			"opens":    "09:30:00",
+		}},
+		{"arrays of tables", "[[servers]]\nname = \"a\"\n[[servers]]\nname = \"b\"\n", map[string]any{
+			"servers": []map[string]any{{"name": "a"}, {"name": "b"}},
+		}},
+		{"arrays", "watch_exts = [\".go\", \".mod\"]\nports = [8080, 8081]\n", map[string]any{
+			"watch_exts": []any{".go", ".mod"},
+			"ports":      []any{int64(8080), int64(8081)},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeTOML([]byte(tt.toml))
+			if err != nil {
+				t.Fatalf("decodeTOML: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeTOML = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeTOMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		toml string
+	}{
+		{"missing value", "binary_name =\n"},
+		{"duplicate key", "binary_name = \"a\"\nbinary_name = \"b\"\n"},
+		{"unclosed string", "binary_name = \"app\n"},
+		{"leading zero", "umask = 022\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeTOML([]byte(tt.toml)); err == nil {
+				t.Errorf("decodeTOML(%q) succeeded, want an error", tt.toml)
+			}
+		})
+	}
+}
+
+func TestTOMLConfig(t *testing.T) {
+	data := []byte(`watch_exts = [".go", ".tmpl"]
+args = ["--port", 8080]
+build_id = 42
+max_restarts = 2
+
+[env]
+PORT = 8080
+DEBUG = true
+RELEASED = 2024-01-02
+`)
+	var c Config
+	if _, err := configDecoders[".toml"].Decode(data, &c); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if want := map[string]string{"PORT": "8080", "DEBUG": "true", "RELEASED": "2024-01-02"}; !reflect.DeepEqual(c.Env, want) {
+		t.Errorf("Env = %v, want %v", c.Env, want)
+	}
+	if want := []string{"--port", "8080"}; !reflect.DeepEqual(c.Args, want) {
+		t.Errorf("Args = %v, want %v", c.Args, want)
+	}
+	if c.BuildID != "42" {
+		t.Errorf("BuildID = %q, want 42", c.BuildID)
+	}
+	if want := []string{".go", ".tmpl"}; !reflect.DeepEqual(c.WatchExts, want) {
+		t.Errorf("WatchExts = %v, want %v", c.WatchExts, want)
+	}
+	if c.MaxRestarts != 2 {
+		t.Errorf("MaxRestarts = %d, want 2", c.MaxRestarts)
+	}
+}
+
+func TestEncodeTOML(t *testing.T) {
+	want := config
+	want.Env = map[string]string{"PORT": "8080", "NAME": "a \"quoted\" name"}
+	want.CGOFlags = map[string]string{}
+	want.WatchExts = []string{".go", ".mod"}
+	data, err := encodeTOML(want)
+	if err != nil {
+		t.Fatalf("encodeTOML: %v", err)
+	}
+	var got Config
+	if _, err := configDecoders[".toml"].Decode(data, &got); err != nil {
+		t.Fatalf("Decode: %v\n%s", err, data)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("encodeTOML did not round trip:\n%s", data)
+	}
+}
@@ -0,0 +1,221 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+const kqueueMask = syscall.NOTE_WRITE | syscall.NOTE_EXTEND | syscall.NOTE_ATTRIB |
+	syscall.NOTE_DELETE | syscall.NOTE_RENAME | syscall.NOTE_LINK
+
+// dirWatcher reports changes in a set of directories using kqueue. kqueue
+// watches open files, and a directory only reports entries being added or
+// removed, so every file in the directories is opened and watched as well.
+type dirWatcher struct {
+	kq      int
+	wake    [2]int
+	mu      sync.Mutex
+	paths   map[int]string
+	fds     map[string]int
+	skip    func(dir string) bool
+	changed func()
+	done    chan struct{}
+}
+
+// Watch dirs and call changed after every batch of events. Directories
+// created later are watched as well unless skip reports that they should not
+// be.
+func newDirWatcher(dirs []string, skip func(dir string) bool, changed func()) (*dirWatcher, error) {
+	kq, err := syscall.Kqueue()
+	if err != nil {
+		return nil, os.NewSyscallError("kqueue", err)
+	}
+	syscall.CloseOnExec(kq)
+
+	w := &dirWatcher{
+		kq:      kq,
+		paths:   make(map[int]string),
+		fds:     make(map[string]int),
+		skip:    skip,
+		changed: changed,
+		done:    make(chan struct{}),
+	}
+	// Closing the write end of the pipe wakes the reader to stop
+	if err := syscall.Pipe(w.wake[:]); err != nil {
+		syscall.Close(kq)
+		return nil, os.NewSyscallError("pipe", err)
+	}
+	syscall.CloseOnExec(w.wake[0])
+	syscall.CloseOnExec(w.wake[1])
+	changes := make([]syscall.Kevent_t, 1)
+	syscall.SetKevent(&changes[0], w.wake[0], syscall.EVFILT_READ, syscall.EV_ADD|syscall.EV_ENABLE)
+	if _, err := syscall.Kevent(kq, changes, nil, nil); err != nil {
+		w.closeAll()
+		syscall.Close(w.wake[1])
+		return nil, os.NewSyscallError("kevent", err)
+	}
+
+	for _, dir := range dirs {
+		// Directories removed since they were listed do not need watching
+		if err := w.addDir(dir); err != nil && !errors.Is(err, syscall.ENOENT) {
+			w.closeAll()
+			syscall.Close(w.wake[1])
+			return nil, err
+		}
+	}
+	go w.read()
+	return w, nil
+}
+
+// Watch a directory and the files in it.
+func (w *dirWatcher) addDir(dir string) error {
+	if err := w.add(dir); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		if err := w.add(filepath.Join(dir, entry.Name())); err != nil && !errors.Is(err, syscall.ENOENT) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Watch a file or directory, unless it is watched already.
+func (w *dirWatcher) add(path string) error {
+	w.mu.Lock()
+	_, ok := w.fds[path]
+	w.mu.Unlock()
+	if ok {
+		return nil
+	}
+
+	fd, err := syscall.Open(path, syscall.O_RDONLY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	changes := make([]syscall.Kevent_t, 1)
+	syscall.SetKevent(&changes[0], fd, syscall.EVFILT_VNODE, syscall.EV_ADD|syscall.EV_CLEAR|syscall.EV_ENABLE)
+	changes[0].Fflags = kqueueMask
+	if _, err := syscall.Kevent(w.kq, changes, nil, nil); err != nil {
+		syscall.Close(fd)
+		return &os.PathError{Op: "kevent", Path: path, Err: err}
+	}
+	w.mu.Lock()
+	w.paths[fd] = path
+	w.fds[path] = fd
+	w.mu.Unlock()
+	return nil
+}
+
+// Stop watching a file or directory that was removed or renamed. A file
+// created under its name is watched again when its directory is read.
+func (w *dirWatcher) remove(fd int) {
+	w.mu.Lock()
+	delete(w.fds, w.paths[fd])
+	delete(w.paths, fd)
+	w.mu.Unlock()
+	syscall.Close(fd)
+}
+
+// Read events until the watcher is closed. When the entries of a directory
+// change, the files in it are watched, and directories created in it are
+// watched along with everything below them.
+func (w *dirWatcher) read() {
+	defer close(w.done)
+	defer w.closeAll()
+	events := make([]syscall.Kevent_t, 64)
+	for {
+		n, err := syscall.Kevent(w.kq, nil, events, nil)
+		if errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		if err != nil {
+			return
+		}
+
+		for _, event := range events[:n] {
+			fd := int(event.Ident)
+			if fd == w.wake[0] {
+				return
+			}
+			w.mu.Lock()
+			path, ok := w.paths[fd]
+			w.mu.Unlock()
+			if !ok {
+				continue
+			}
+
+			if event.Fflags&(syscall.NOTE_DELETE|syscall.NOTE_RENAME) != 0 {
+				w.remove(fd)
+				continue
+			}
+			if event.Fflags&syscall.NOTE_WRITE == 0 {
+				continue
+			}
+			// Only directories need reading again, a written file is
+			// picked up by the walk after the tick
+			if info, err := os.Lstat(path); err != nil || !info.IsDir() {
+				continue
+			}
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				continue
+			}
+			for _, entry := range entries {
+				child := filepath.Join(path, entry.Name())
+				switch {
+				case entry.Type().IsRegular():
+					w.add(child)
+				case entry.IsDir():
+					w.mu.Lock()
+					_, watched := w.fds[child]
+					w.mu.Unlock()
+					if watched {
+						continue
+					}
+					filepath.WalkDir(child, func(path string, d os.DirEntry, err error) error {
+						if err != nil || !d.IsDir() {
+							return nil
+						}
+						if w.skip(path) {
+							return filepath.SkipDir
+						}
+						w.addDir(path)
+						return nil
+					})
+				}
+			}
+		}
+		w.changed()
+	}
+}
+
+// Stop watching and wait for the reader to finish.
+func (w *dirWatcher) Close() {
+	syscall.Close(w.wake[1])
+	<-w.done
+}
+
+// Close the kqueue, the pipe and every watched file.
+func (w *dirWatcher) closeAll() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for fd := range w.paths {
+		syscall.Close(fd)
+	}
+	w.paths, w.fds = nil, nil
+	syscall.Close(w.kq)
+	syscall.Close(w.wake[0])
+}
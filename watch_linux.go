@@ -0,0 +1,129 @@
+//go:build linux
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY |
+	syscall.IN_ATTRIB | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+
+// dirWatcher reports changes in a set of directories using inotify.
+type dirWatcher struct {
+	fd      int
+	file    *os.File
+	mu      sync.Mutex
+	dirs    map[int]string
+	skip    func(dir string) bool
+	changed func()
+	done    chan struct{}
+}
+
+// Watch dirs and call changed after every batch of events. Directories
+// created later are watched as well unless skip reports that they should not
+// be.
+func newDirWatcher(dirs []string, skip func(dir string) bool, changed func()) (*dirWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	w := &dirWatcher{
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		dirs:    make(map[int]string),
+		skip:    skip,
+		changed: changed,
+		done:    make(chan struct{}),
+	}
+	for _, dir := range dirs {
+		// Directories removed since they were listed do not need watching
+		if err := w.add(dir); err != nil && !errors.Is(err, syscall.ENOENT) {
+			w.file.Close()
+			return nil, err
+		}
+	}
+	go w.read()
+	return w, nil
+}
+
+// Watch a directory.
+func (w *dirWatcher) add(dir string) error {
+	wd, err := syscall.InotifyAddWatch(w.fd, dir, inotifyMask)
+	if err != nil {
+		return &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+	w.mu.Lock()
+	w.dirs[wd] = dir
+	w.mu.Unlock()
+	return nil
+}
+
+// Read events until the watcher is closed. Directories created or moved into
+// a watched directory are watched as well, along with everything below them.
+func (w *dirWatcher) read() {
+	defer close(w.done)
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			wd := int(int32(binary.NativeEndian.Uint32(buf[offset:])))
+			mask := binary.NativeEndian.Uint32(buf[offset+4:])
+			nameLen := int(binary.NativeEndian.Uint32(buf[offset+12:]))
+			name := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+nameLen]
+			offset += syscall.SizeofInotifyEvent + nameLen
+
+			// The watch of a removed directory is gone, so forget its path
+			if mask&syscall.IN_IGNORED != 0 {
+				w.mu.Lock()
+				delete(w.dirs, wd)
+				w.mu.Unlock()
+				continue
+			}
+
+			if mask&syscall.IN_ISDIR != 0 && mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+				w.mu.Lock()
+				parent := w.dirs[wd]
+				w.mu.Unlock()
+				root := filepath.Join(parent, string(trimNul(name)))
+				filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+					if err != nil || !d.IsDir() {
+						return nil
+					}
+					if w.skip(path) {
+						return filepath.SkipDir
+					}
+					w.add(path)
+					return nil
+				})
+			}
+		}
+		w.changed()
+	}
+}
+
+// Stop watching and wait for the reader to finish.
+func (w *dirWatcher) Close() {
+	w.file.Close()
+	<-w.done
+}
+
+// Strip the NUL padding inotify adds after file names.
+func trimNul(name []byte) []byte {
+	for i, b := range name {
+		if b == 0 {
+			return name[:i]
+		}
+	}
+	return name
+}
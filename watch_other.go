@@ -0,0 +1,18 @@
+//go:build !linux && !windows && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Native file watching is not supported on this platform, so dirWatcher is
+// never created.
+type dirWatcher struct{}
+
+func newDirWatcher(dirs []string, skip func(dir string) bool, changed func()) (*dirWatcher, error) {
+	return nil, fmt.Errorf("native file watching is not supported on %s", runtime.GOOS)
+}
+
+func (w *dirWatcher) Close() {}
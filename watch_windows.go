@@ -0,0 +1,203 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const dirChangesMask = syscall.FILE_NOTIFY_CHANGE_FILE_NAME | syscall.FILE_NOTIFY_CHANGE_DIR_NAME |
+	syscall.FILE_NOTIFY_CHANGE_ATTRIBUTES | syscall.FILE_NOTIFY_CHANGE_SIZE |
+	syscall.FILE_NOTIFY_CHANGE_LAST_WRITE | syscall.FILE_NOTIFY_CHANGE_CREATION
+
+// A directory watched with ReadDirectoryChangesW. The system writes the
+// changes to buf while a read is pending. It is made of uint32s because the
+// records in it must be aligned to them.
+type dirHandle struct {
+	ov     syscall.Overlapped
+	handle syscall.Handle
+	path   string
+	buf    [4096]uint32
+}
+
+// dirWatcher reports changes in a set of directories using
+// ReadDirectoryChangesW, with the reads of every directory completing on one
+// I/O completion port.
+type dirWatcher struct {
+	port    syscall.Handle
+	mu      sync.Mutex
+	dirs    map[uint32]*dirHandle
+	paths   map[string]bool
+	nextKey uint32
+	skip    func(dir string) bool
+	changed func()
+	done    chan struct{}
+}
+
+// Watch dirs and call changed after every batch of events. Directories
+// created later are watched as well unless skip reports that they should not
+// be.
+func newDirWatcher(dirs []string, skip func(dir string) bool, changed func()) (*dirWatcher, error) {
+	port, err := syscall.CreateIoCompletionPort(syscall.InvalidHandle, 0, 0, 0)
+	if err != nil {
+		return nil, os.NewSyscallError("CreateIoCompletionPort", err)
+	}
+
+	w := &dirWatcher{
+		port:    port,
+		dirs:    make(map[uint32]*dirHandle),
+		paths:   make(map[string]bool),
+		skip:    skip,
+		changed: changed,
+		done:    make(chan struct{}),
+	}
+	for _, dir := range dirs {
+		// Directories removed since they were listed do not need watching
+		if err := w.add(dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+			w.closeAll()
+			return nil, err
+		}
+	}
+	go w.read()
+	return w, nil
+}
+
+// Watch a directory, unless it is watched already.
+func (w *dirWatcher) add(dir string) error {
+	w.mu.Lock()
+	watched := w.paths[dir]
+	w.mu.Unlock()
+	if watched {
+		return nil
+	}
+
+	name, err := syscall.UTF16PtrFromString(dir)
+	if err != nil {
+		return &os.PathError{Op: "CreateFile", Path: dir, Err: err}
+	}
+	handle, err := syscall.CreateFile(name, syscall.FILE_LIST_DIRECTORY,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE, nil,
+		syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS|syscall.FILE_FLAG_OVERLAPPED, 0)
+	if err != nil {
+		return &os.PathError{Op: "CreateFile", Path: dir, Err: err}
+	}
+
+	// Key zero is left for Close
+	w.mu.Lock()
+	w.nextKey++
+	key := w.nextKey
+	d := &dirHandle{handle: handle, path: dir}
+	w.dirs[key] = d
+	w.paths[dir] = true
+	w.mu.Unlock()
+
+	if _, err := syscall.CreateIoCompletionPort(handle, w.port, key, 0); err != nil {
+		w.remove(key)
+		return &os.PathError{Op: "CreateIoCompletionPort", Path: dir, Err: err}
+	}
+	if err := d.readChanges(); err != nil {
+		w.remove(key)
+		return &os.PathError{Op: "ReadDirectoryChanges", Path: dir, Err: err}
+	}
+	return nil
+}
+
+// Start a read of the changes in the directory, which completes on the port.
+func (d *dirHandle) readChanges() error {
+	d.ov = syscall.Overlapped{}
+	return syscall.ReadDirectoryChanges(d.handle, (*byte)(unsafe.Pointer(&d.buf[0])), uint32(len(d.buf)*4),
+		false, dirChangesMask, nil, &d.ov, 0)
+}
+
+// Stop watching a directory whose read failed, usually because it was
+// removed.
+func (w *dirWatcher) remove(key uint32) {
+	w.mu.Lock()
+	d := w.dirs[key]
+	delete(w.dirs, key)
+	delete(w.paths, d.path)
+	w.mu.Unlock()
+	syscall.CloseHandle(d.handle)
+}
+
+// Read changes until the watcher is closed. Directories created or moved into
+// a watched directory are watched as well, along with everything below them.
+func (w *dirWatcher) read() {
+	defer close(w.done)
+	defer w.closeAll()
+	for {
+		var n, key uint32
+		var ov *syscall.Overlapped
+		err := syscall.GetQueuedCompletionStatus(w.port, &n, &key, &ov, syscall.INFINITE)
+		// Nothing was read, either because Close woke the reader or
+		// because the port failed
+		if ov == nil {
+			return
+		}
+		w.mu.Lock()
+		d := w.dirs[key]
+		w.mu.Unlock()
+		if d == nil {
+			continue
+		}
+		if err != nil {
+			w.remove(key)
+			w.changed()
+			continue
+		}
+
+		// No changes are returned when there were more than fit in the
+		// buffer, and the walk after the tick finds them all
+		buf := unsafe.Slice((*byte)(unsafe.Pointer(&d.buf[0])), len(d.buf)*4)
+		for offset := uint32(0); n > 0 && offset < n; {
+			info := (*syscall.FileNotifyInformation)(unsafe.Pointer(&buf[offset]))
+			name := syscall.UTF16ToString(unsafe.Slice(&info.FileName, info.FileNameLength/2))
+			if info.Action == syscall.FILE_ACTION_ADDED || info.Action == syscall.FILE_ACTION_RENAMED_NEW_NAME {
+				root := filepath.Join(d.path, name)
+				if fi, err := os.Lstat(root); err == nil && fi.IsDir() {
+					filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+						if err != nil || !d.IsDir() {
+							return nil
+						}
+						if w.skip(path) {
+							return filepath.SkipDir
+						}
+						w.add(path)
+						return nil
+					})
+				}
+			}
+			if info.NextEntryOffset == 0 {
+				break
+			}
+			offset += info.NextEntryOffset
+		}
+
+		if err := d.readChanges(); err != nil {
+			w.remove(key)
+		}
+		w.changed()
+	}
+}
+
+// Stop watching and wait for the reader to finish.
+func (w *dirWatcher) Close() {
+	syscall.PostQueuedCompletionStatus(w.port, 0, 0, nil)
+	<-w.done
+}
+
+// Close the handles of the directories and the port.
+func (w *dirWatcher) closeAll() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, d := range w.dirs {
+		syscall.CloseHandle(d.handle)
+	}
+	w.dirs, w.paths = nil, nil
+	syscall.CloseHandle(w.port)
+}
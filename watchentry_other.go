@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// File ownership is not available on this platform.
+func fileOwner(info os.FileInfo) string {
+	return ""
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Decode a YAML document into a map of its keys. Scalars for string options,
+// their list items and map values keep the text they were written with, so
+// that umask: 022, min_go_version: 1.20 and PORT: 8080 are not read as
+// numbers first.
+func decodeYAML(data []byte) (map[string]any, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	values := make(map[string]any)
+	if len(doc.Content) == 0 {
+		return values, nil
+	}
+	root := yamlResolve(doc.Content[0])
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: expected a mapping of config keys", root.Line)
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key := root.Content[i].Value
+		value, err := yamlValue(root.Content[i+1], configFieldType(key))
+		if err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// Convert a node to a value for a field of type t. Nodes for other fields,
+// and for keys that are not options, are decoded as YAML reads them.
+func yamlValue(node *yaml.Node, t reflect.Type) (any, error) {
+	node = yamlResolve(node)
+	if t != nil && node.Tag != "!!null" {
+		switch {
+		case t.Kind() == reflect.String && node.Kind == yaml.ScalarNode:
+			return node.Value, nil
+		case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String && node.Kind == yaml.SequenceNode:
+			items := make([]any, len(node.Content))
+			for i, item := range node.Content {
+				value, err := yamlValue(item, t.Elem())
+				if err != nil {
+					return nil, err
+				}
+				items[i] = value
+			}
+			return items, nil
+		case t.Kind() == reflect.Map && t.Elem().Kind() == reflect.String && node.Kind == yaml.MappingNode:
+			values := make(map[string]any)
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				value, err := yamlValue(node.Content[i+1], t.Elem())
+				if err != nil {
+					return nil, err
+				}
+				values[yamlResolve(node.Content[i]).Value] = value
+			}
+			return values, nil
+		}
+	}
+	var value any
+	if err := node.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Follow an alias to the node it refers to.
+func yamlResolve(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want map[string]any
+	}{
+		{"empty", "", map[string]any{}},
+		{"comment only", "# nothing yet\n", map[string]any{}},
+		{"scalars", "binary_name: app\nmax_restarts: 3\nwatch_only: true\n", map[string]any{
+			"binary_name":  "app",
+			"max_restarts": 3,
+			"watch_only":   true,
+		}},
+		{"string options keep their text", "umask: 022\nmin_go_version: 1.20\nbuild_id: 0x10\n", map[string]any{
+			"umask":          "022",
+			"min_go_version": "1.20",
+			"build_id":       "0x10",
+		}},
+		{"map values keep their text", "env:\n  PORT: 8080\n  DEBUG: true\n  RATIO: 0.50\n", map[string]any{
+			"env": map[string]any{"PORT": "8080", "DEBUG": "true", "RATIO": "0.50"},
+		}},
+		{"flow map", "env: {PORT: 8080, NAME: \"a b\"}\n", map[string]any{
+			"env": map[string]any{"PORT": "8080", "NAME": "a b"},
+		}},
+		{"list items keep their text", "watch_exts:\n  - .go\n  - 1\nargs: [--port, 8080]\n", map[string]any{
+			"watch_exts": []any{".go", "1"},
+			"args":       []any{"--port", "8080"},
+		}},
+		{"null", "umask: ~\nenv:\n", map[string]any{"umask": nil, "env": nil}},
+		{"block scalar", "migration_command: |\n  migrate up\n  seed\n", map[string]any{
+			"migration_command": "migrate up\nseed\n",
+		}},
+		{"anchors", "base: &dirs [cmd, internal]\nwatch_dirs: *dirs\n", map[string]any{
+			"base":       []any{"cmd", "internal"},
+			"watch_dirs": []any{"cmd", "internal"},
+		}},
+		{"unknown keys are decoded as YAML reads them", "extra: 8080\n", map[string]any{"extra": 8080}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeYAML([]byte(tt.yaml))
+			if err != nil {
+				t.Fatalf("decodeYAML: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeYAML = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+	}{
+		{"not a mapping", "- binary_name\n"},
+		{"tab indentation", "env:\n\tPORT: 8080\n"},
+		{"unclosed flow", "args: [--port\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeYAML([]byte(tt.yaml)); err == nil {
+				t.Errorf("decodeYAML(%q) succeeded, want an error", tt.yaml)
+			}
+		})
+	}
+}
+
+func TestYAMLConfig(t *testing.T) {
+	data := []byte(`env: {PORT: 8080, DEBUG: true}
+min_go_version: 1.21
+umask: 022
+watch_exts: [.go, .tmpl]
+max_restarts: 2
+`)
+	var c Config
+	if _, err := configDecoders[".yaml"].Decode(data, &c); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if want := map[string]string{"PORT": "8080", "DEBUG": "true"}; !reflect.DeepEqual(c.Env, want) {
+		t.Errorf("Env = %v, want %v", c.Env, want)
+	}
+	if c.MinGoVersion != "1.21" {
+		t.Errorf("MinGoVersion = %q, want 1.21", c.MinGoVersion)
+	}
+	if c.UMask != "022" {
+		t.Errorf("UMask = %q, want 022", c.UMask)
+	}
+	if want := []string{".go", ".tmpl"}; !reflect.DeepEqual(c.WatchExts, want) {
+		t.Errorf("WatchExts = %v, want %v", c.WatchExts, want)
+	}
+	if c.MaxRestarts != 2 {
+		t.Errorf("MaxRestarts = %d, want 2", c.MaxRestarts)
+	}
+}